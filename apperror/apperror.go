@@ -0,0 +1,53 @@
+// Package apperror defines the categories of error that the game server reports to clients
+package apperror
+
+import "errors"
+
+// Error categories, compare against them with errors.Is
+var (
+	ErrNotFound     = errors.New("Not found")
+	ErrUnauthorized = errors.New("Unauthorized")
+	ErrForbidden    = errors.New("Forbidden")
+	ErrInvalidState = errors.New("Invalid state")
+	ErrValidation   = errors.New("Validation failed")
+)
+
+// Error - An error with a user facing message that belongs to one of the error categories
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error's category
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// NotFound creates an error for a resource that does not exist
+func NotFound(message string) error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+// Unauthorized creates an error for a request whose user could not be identified
+func Unauthorized(message string) error {
+	return &Error{Kind: ErrUnauthorized, Message: message}
+}
+
+// Forbidden creates an error for a user attempting something they are not allowed to do
+func Forbidden(message string) error {
+	return &Error{Kind: ErrForbidden, Message: message}
+}
+
+// InvalidState creates an error for an action that is not possible at the moment
+func InvalidState(message string) error {
+	return &Error{Kind: ErrInvalidState, Message: message}
+}
+
+// Validation creates an error for malformed or unacceptable input
+func Validation(message string) error {
+	return &Error{Kind: ErrValidation, Message: message}
+}
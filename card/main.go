@@ -3,45 +3,106 @@ package card
 import (
 	"database/sql"
 	"fmt"
-	"log"
-	"strconv"
+
+	"github.com/lib/pq"
+
+	"../apperror"
 )
 
+// PostgresStore - A card store backed by the cardpacks and cards tables
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// CreatePostgresStore generates a card store that reads from the given database
+func CreatePostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// ListPacks .
+func (s *PostgresStore) ListPacks() ([]Cardpack, error) {
+	rows, err := s.db.Query(`SELECT id, name FROM cardpacks ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	packs := []Cardpack{}
+	for rows.Next() {
+		var cp Cardpack
+		if err := rows.Scan(&cp.ID, &cp.Name); err != nil {
+			return nil, err
+		}
+		packs = append(packs, cp)
+	}
+	return packs, rows.Err()
+}
+
 // GetCards .
-func GetCards(cpids []int, db *sql.DB) ([]BlackCard, []WhiteCard) {
-	_, err := db.Query("SELECT id FROM cardpacks WHERE" + generateOrQuery(`"id"`, intSliceToStringSlice(cpids)))
+func (s *PostgresStore) GetCards(cpids []int) ([]BlackCard, []WhiteCard, error) {
+	var packCount int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM cardpacks WHERE id = ANY($1)`, pq.Array(cpids)).Scan(&packCount)
 	if err != nil {
+		return nil, nil, err
+	}
+	if packCount != countUnique(cpids) {
 		fmt.Printf("Error: one or more cardpack ID is invalid - %v", cpids)
-		return nil, nil
+		return nil, nil, apperror.Validation("One or more cardpack IDs are invalid")
 	}
-	rows, err := db.Query("SELECT * FROM cards WHERE" + generateOrQuery(`"cardpackId"`, intSliceToStringSlice(cpids)))
+	rows, err := s.db.Query(`SELECT * FROM cards WHERE "cardpackId" = ANY($1)`, pq.Array(cpids))
 	if err != nil {
 		fmt.Println("Error reading cards from database:", err)
-		return nil, nil
+		return nil, nil, err
 	}
 
 	defer rows.Close()
 	bc := []BlackCard{}
 	wc := []WhiteCard{}
 	for rows.Next() {
-		var id int
-		var text string
-		var ctype string
-		var answerFields sql.NullInt64
-		var createdAt string
-		var updatedAt string
-		var cardpackID int
-		if err := rows.Scan(&id, &text, &ctype, &answerFields, &createdAt, &updatedAt, &cardpackID); err != nil {
-			log.Fatal(err)
+		c, err := scanCard(rows)
+		if err != nil {
+			return nil, nil, err
 		}
 
-		if ctype == "black" {
-			bc = append(bc, CreateBlackCard(id, text, int(answerFields.Int64), cardpackID))
+		if c.Type == "black" {
+			bc = append(bc, BlackCard{Card: c})
 		} else {
-			wc = append(wc, CreateWhiteCard(id, text, cardpackID))
+			wc = append(wc, WhiteCard{Card: c})
 		}
 	}
-	return bc, wc
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	return bc, wc, nil
+}
+
+// GetCard .
+func (s *PostgresStore) GetCard(id int) (Card, error) {
+	rows, err := s.db.Query(`SELECT * FROM cards WHERE id = $1`, id)
+	if err != nil {
+		return Card{}, err
+	}
+	defer rows.Close()
+	if !rows.Next() {
+		return Card{}, apperror.NotFound("Card does not exist")
+	}
+	return scanCard(rows)
+}
+
+func scanCard(rows *sql.Rows) (Card, error) {
+	var id int
+	var text string
+	var ctype string
+	var answerFields sql.NullInt64
+	var createdAt string
+	var updatedAt string
+	var cardpackID int
+	if err := rows.Scan(&id, &text, &ctype, &answerFields, &createdAt, &updatedAt, &cardpackID); err != nil {
+		return Card{}, err
+	}
+	if ctype == "black" {
+		return CreateBlackCard(id, text, int(answerFields.Int64), cardpackID).Card, nil
+	}
+	return CreateWhiteCard(id, text, cardpackID).Card, nil
 }
 
 func intsToBytes(nl []uint8) []byte {
@@ -52,21 +113,10 @@ func intsToBytes(nl []uint8) []byte {
 	return b
 }
 
-func generateOrQuery(fieldName string, elems []string) string {
-	query := ""
-	for i, e := range elems {
-		query += " " + fieldName + " = " + e
-		if i < len(elems)-1 {
-			query += " OR"
-		}
-	}
-	return query
-}
-
-func intSliceToStringSlice(ints []int) []string {
-	s := make([]string, len(ints))
-	for i, n := range ints {
-		s[i] = strconv.Itoa(n)
+func countUnique(ints []int) int {
+	seen := make(map[int]bool)
+	for _, n := range ints {
+		seen[n] = true
 	}
-	return s
+	return len(seen)
 }
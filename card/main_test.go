@@ -1,19 +1,63 @@
 package card
 
-import "testing"
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
 
-func TestGenerateOrQuery(t *testing.T) {
-	var q1, q2 string
+	"../apperror"
+	"../dbtest"
+)
 
-	q1 = generateOrQuery("ID", []string{"1", "2", "3"})
-	q2 = " ID = 1 OR ID = 2 OR ID = 3"
-	if q1 != q2 {
-		t.Errorf("Failed: Expected...\n%s\nto equal...\n%s", q1, q2)
+func TestGetCardsUsesPlaceholders(t *testing.T) {
+	db, rec := dbtest.Open(func(q dbtest.Query) ([]string, [][]driver.Value) {
+		if strings.Contains(q.SQL, "COUNT") {
+			return []string{"count"}, [][]driver.Value{{int64(2)}}
+		}
+		return []string{"id", "text", "type", "answerFields", "createdAt", "updatedAt", "cardpackId"}, [][]driver.Value{
+			{int64(1), "Why?", "black", int64(1), "", "", int64(4)},
+			{int64(2), "Because", "white", nil, "", "", int64(5)},
+		}
+	})
+	defer db.Close()
+
+	bc, wc, err := CreatePostgresStore(db).GetCards([]int{4, 5})
+	if err != nil {
+		t.Fatalf("Failed: Could not get cards - %v", err)
+	}
+	if len(bc) != 1 || len(wc) != 1 {
+		t.Fatalf("Failed: Expected 1 black and 1 white card, got %d and %d", len(bc), len(wc))
+	}
+	for _, q := range rec.Queries() {
+		if len(q.Args) != 1 || q.Args[0] != "{4,5}" {
+			t.Errorf("Failed: Expected pack IDs to be passed as an array argument, got %v", q.Args)
+			continue
+		}
+		if !strings.Contains(q.SQL, "ANY($1)") || strings.Contains(q.SQL, "4,5") || strings.Contains(q.SQL, "4, 5") {
+			t.Errorf("Failed: Expected pack IDs to be bound to the $1 placeholder rather than written into...\n%s", q.SQL)
+		}
 	}
+}
+
+func TestGetCardsRejectsUnknownPacks(t *testing.T) {
+	db, _ := dbtest.Open(func(q dbtest.Query) ([]string, [][]driver.Value) {
+		return []string{"count"}, [][]driver.Value{{int64(1)}}
+	})
+	defer db.Close()
+
+	_, _, err := CreatePostgresStore(db).GetCards([]int{4, -1})
+	if !errors.Is(err, apperror.ErrValidation) {
+		t.Errorf("Failed: Expected a validation error when a pack ID does not exist, got %v", err)
+	}
+}
+
+func TestGetCardsReturnsDatabaseErrors(t *testing.T) {
+	db, _ := dbtest.Open(nil)
+	db.Close()
 
-	q1 = generateOrQuery("cardpackId", []string{"4"})
-	q2 = " cardpackId = 4"
-	if q1 != q2 {
-		t.Errorf("Failed: Expected...\n%s\nto equal...\n%s", q1, q2)
+	_, _, err := CreatePostgresStore(db).GetCards([]int{4})
+	if err == nil || errors.Is(err, apperror.ErrValidation) {
+		t.Errorf("Failed: Expected a failed query to be returned as is, got %v", err)
 	}
 }
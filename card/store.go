@@ -0,0 +1,96 @@
+package card
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	"../apperror"
+)
+
+// Cardpack .
+type Cardpack struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Store provides access to cardpacks and their cards
+type Store interface {
+	ListPacks() ([]Cardpack, error)
+	GetCards(cpids []int) ([]BlackCard, []WhiteCard, error)
+	GetCard(id int) (Card, error)
+}
+
+// MemoryStore - A card store that keeps every cardpack and card in memory
+type MemoryStore struct {
+	packs []Cardpack
+	cards []Card
+}
+
+// CreateMemoryStore generates a card store holding the given cardpacks and cards
+func CreateMemoryStore(packs []Cardpack, cards []Card) *MemoryStore {
+	return &MemoryStore{packs: packs, cards: cards}
+}
+
+// LoadMemoryStore generates a card store from a JSON fixture file of the form {"cardpacks": [...], "cards": [...]}
+func LoadMemoryStore(path string) (*MemoryStore, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures struct {
+		Cardpacks []Cardpack `json:"cardpacks"`
+		Cards     []Card     `json:"cards"`
+	}
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, err
+	}
+	return CreateMemoryStore(fixtures.Cardpacks, fixtures.Cards), nil
+}
+
+// ListPacks .
+func (s *MemoryStore) ListPacks() ([]Cardpack, error) {
+	return append([]Cardpack{}, s.packs...), nil
+}
+
+// GetCards .
+func (s *MemoryStore) GetCards(cpids []int) ([]BlackCard, []WhiteCard, error) {
+	wanted := make(map[int]bool)
+	for _, id := range cpids {
+		if !s.hasPack(id) {
+			return nil, nil, apperror.Validation("One or more cardpack IDs are invalid")
+		}
+		wanted[id] = true
+	}
+	bc := []BlackCard{}
+	wc := []WhiteCard{}
+	for _, c := range s.cards {
+		if !wanted[c.CardpackID] {
+			continue
+		}
+		if c.Type == "black" {
+			bc = append(bc, BlackCard{Card: c})
+		} else {
+			wc = append(wc, WhiteCard{Card: c})
+		}
+	}
+	return bc, wc, nil
+}
+
+// GetCard .
+func (s *MemoryStore) GetCard(id int) (Card, error) {
+	for _, c := range s.cards {
+		if c.ID == id {
+			return c, nil
+		}
+	}
+	return Card{}, apperror.NotFound("Card does not exist")
+}
+
+func (s *MemoryStore) hasPack(id int) bool {
+	for _, cp := range s.packs {
+		if cp.ID == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,35 @@
+package card
+
+import "testing"
+
+func TestMemoryStore(t *testing.T) {
+	s, err := LoadMemoryStore("testdata/fixtures.json")
+	if err != nil {
+		t.Fatalf("Failed: Could not load fixtures - %v", err)
+	}
+
+	packs, _ := s.ListPacks()
+	if len(packs) != 2 {
+		t.Errorf("Failed: Expected 2 cardpacks, got %d", len(packs))
+	}
+
+	bc, wc, err := s.GetCards([]int{1})
+	if err != nil || len(bc) != 1 || len(wc) != 2 {
+		t.Errorf("Failed: Expected 1 black and 2 white cards from pack 1, got %d and %d (%v)", len(bc), len(wc), err)
+	}
+	if bc[0].AnswerFields != 1 {
+		t.Errorf("Failed: Expected black card to keep its answer fields")
+	}
+
+	if _, _, err := s.GetCards([]int{1, 3}); err == nil {
+		t.Errorf("Failed: Expected an error for an unknown cardpack")
+	}
+
+	c, err := s.GetCard(5)
+	if err != nil || c.Text != "Tentacle porn." {
+		t.Errorf("Failed: Expected to fetch card 5, got %v (%v)", c, err)
+	}
+	if _, err := s.GetCard(99); err == nil {
+		t.Errorf("Failed: Expected an error for an unknown card")
+	}
+}
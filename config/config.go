@@ -0,0 +1,273 @@
+// Package config loads server configuration from a JSON or YAML file, environment variables and flags
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"../gamelist/game"
+	"../snapshot"
+	"../user"
+)
+
+// Config - Everything needed to run the game server. Later sources override earlier ones:
+// defaults, then the config file, then CARDS_* environment variables, then command line flags.
+type Config struct {
+	DatabaseDSN            string   `json:"databaseDsn" yaml:"databaseDsn"`
+	ListenAddress          string   `json:"listenAddress" yaml:"listenAddress"`
+	AllowedOrigins         []string `json:"allowedOrigins" yaml:"allowedOrigins"`
+	AuthMode               string   `json:"authMode" yaml:"authMode"`
+	AuthSecret             string   `json:"authSecret" yaml:"authSecret"`
+	SessionUserKeyPaths    []string `json:"sessionUserKeyPaths" yaml:"sessionUserKeyPaths"`
+	PlayTimeout            int      `json:"playTimeout" yaml:"playTimeout"`               // Seconds
+	JudgeTimeout           int      `json:"judgeTimeout" yaml:"judgeTimeout"`             // Seconds
+	ScoreTimeout           int      `json:"scoreTimeout" yaml:"scoreTimeout"`             // Seconds
+	EliminationTimeout     int      `json:"eliminationTimeout" yaml:"eliminationTimeout"` // Seconds
+	HandSize               int      `json:"handSize" yaml:"handSize"`
+	MinPlayers             int      `json:"minPlayers" yaml:"minPlayers"`
+	MaxPlayers             int      `json:"maxPlayers" yaml:"maxPlayers"`
+	MinBlackCards          int      `json:"minBlackCards" yaml:"minBlackCards"`
+	MinWhiteCardsPerPlayer int      `json:"minWhiteCardsPerPlayer" yaml:"minWhiteCardsPerPlayer"`
+	DrainTimeout           int      `json:"drainTimeout" yaml:"drainTimeout"`       // Seconds
+	ShutdownTimeout        int      `json:"shutdownTimeout" yaml:"shutdownTimeout"` // Seconds
+	ReconnectGrace         int      `json:"reconnectGrace" yaml:"reconnectGrace"`   // Seconds
+	AFKStrikeLimit         int      `json:"afkStrikeLimit" yaml:"afkStrikeLimit"`
+	SnapshotStore          string   `json:"snapshotStore" yaml:"snapshotStore"`
+	SnapshotDir            string   `json:"snapshotDir" yaml:"snapshotDir"`
+	SnapshotInterval       int      `json:"snapshotInterval" yaml:"snapshotInterval"` // Seconds
+}
+
+// option - A setting that can be given as a flag or environment variable
+type option struct {
+	name  string
+	usage string
+	set   func(c *Config, value string) error
+}
+
+var options = []option{
+	{"db-dsn", "Postgres connection string", setString(func(c *Config) *string { return &c.DatabaseDSN })},
+	{"listen-address", "Address for the HTTP/socket server to listen on", setString(func(c *Config) *string { return &c.ListenAddress })},
+	{"allowed-origins", "Comma separated list of CORS origins", setList(func(c *Config) *[]string { return &c.AllowedOrigins })},
+	{"auth-mode", "Authentication mode (session, token or static)", setString(func(c *Config) *string { return &c.AuthMode })},
+	{"auth-secret", "Secret used to verify session cookies or tokens", setString(func(c *Config) *string { return &c.AuthSecret })},
+	{"session-user-key-paths", "Comma separated paths to the user ID in session data", setList(func(c *Config) *[]string { return &c.SessionUserKeyPaths })},
+	{"play-timeout", "Seconds allowed for the card play phase", setInt(func(c *Config) *int { return &c.PlayTimeout })},
+	{"judge-timeout", "Seconds allowed for the judge phase", setInt(func(c *Config) *int { return &c.JudgeTimeout })},
+	{"score-timeout", "Seconds the scoring phase is shown for", setInt(func(c *Config) *int { return &c.ScoreTimeout })},
+	{"elimination-timeout", "Seconds allowed for each turn when eliminating submissions", setInt(func(c *Config) *int { return &c.EliminationTimeout })},
+	{"hand-size", "Default number of cards in a hand", setInt(func(c *Config) *int { return &c.HandSize })},
+	{"min-players", "Players needed to start a game", setInt(func(c *Config) *int { return &c.MinPlayers })},
+	{"max-players", "Largest player limit a game may have", setInt(func(c *Config) *int { return &c.MaxPlayers })},
+	{"min-black-cards", "Black cards needed to create a game", setInt(func(c *Config) *int { return &c.MinBlackCards })},
+	{"min-white-cards-per-player", "White cards needed per player slot to create a game", setInt(func(c *Config) *int { return &c.MinWhiteCardsPerPlayer })},
+	{"drain-timeout", "Seconds running games may continue after a shutdown signal", setInt(func(c *Config) *int { return &c.DrainTimeout })},
+	{"shutdown-timeout", "Seconds to wait for HTTP requests to finish when shutting down", setInt(func(c *Config) *int { return &c.ShutdownTimeout })},
+	{"reconnect-grace", "Seconds a disconnected player keeps their seat before being removed", setInt(func(c *Config) *int { return &c.ReconnectGrace })},
+	{"afk-strike-limit", "Deadlines a player may miss in a row before spectating, 0 for no limit", setInt(func(c *Config) *int { return &c.AFKStrikeLimit })},
+	{"snapshot-store", "Where to keep game snapshots between restarts (file or postgres, empty to disable)", setString(func(c *Config) *string { return &c.SnapshotStore })},
+	{"snapshot-dir", "Directory for game snapshots when using the file snapshot store", setString(func(c *Config) *string { return &c.SnapshotDir })},
+	{"snapshot-interval", "Seconds between game snapshots", setInt(func(c *Config) *int { return &c.SnapshotInterval })},
+}
+
+// Default returns the configuration used when nothing else is given
+func Default() Config {
+	g := game.DefaultConfig()
+	return Config{
+		DatabaseDSN:            "user=student password=student dbname=cards sslmode=disable",
+		ListenAddress:          ":8000",
+		AllowedOrigins:         []string{"http://localhost:3000"},
+		AuthMode:               user.AuthSession,
+		PlayTimeout:            int(g.PlayDuration / time.Second),
+		JudgeTimeout:           int(g.JudgeDuration / time.Second),
+		ScoreTimeout:           int(g.ScoreDuration / time.Second),
+		EliminationTimeout:     int(g.EliminationDuration / time.Second),
+		HandSize:               g.DefaultHandSize,
+		MinPlayers:             g.MinPlayers,
+		MaxPlayers:             g.MaxPlayers,
+		MinBlackCards:          g.MinBlackCards,
+		MinWhiteCardsPerPlayer: g.MinWhiteCardsPerPlayer,
+		DrainTimeout:           120,
+		ShutdownTimeout:        10,
+		ReconnectGrace:         int(g.ReconnectGrace / time.Second),
+		AFKStrikeLimit:         g.AFKStrikeLimit,
+		SnapshotDir:            "snapshots",
+		SnapshotInterval:       30,
+	}
+}
+
+// Load builds and validates the configuration from command line arguments (excluding the program name)
+func Load(args []string) (Config, error) {
+	fs := flag.NewFlagSet("cards_game_server", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv("CARDS_CONFIG"), "Path to a JSON or YAML config file")
+	for _, o := range options {
+		fs.String(o.name, "", o.usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+
+	c := Default()
+	if *path != "" {
+		if err := c.loadFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+	for _, o := range options {
+		if v := os.Getenv(envName(o.name)); v != "" {
+			if err := o.set(&c, v); err != nil {
+				return Config{}, errors.New(envName(o.name) + ": " + err.Error())
+			}
+		}
+	}
+	var err error
+	fs.Visit(func(f *flag.Flag) {
+		for _, o := range options {
+			if o.name == f.Name && err == nil {
+				if e := o.set(&c, f.Value.String()); e != nil {
+					err = errors.New("-" + f.Name + ": " + e.Error())
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	return c, c.Validate()
+}
+
+// Validate checks that the configuration can be used to run the server
+func (c Config) Validate() error {
+	if c.DatabaseDSN == "" {
+		return errors.New("A database connection string is required")
+	}
+	if c.ListenAddress == "" {
+		return errors.New("A listen address is required")
+	}
+	switch c.AuthMode {
+	case user.AuthSession, user.AuthToken:
+		if c.AuthSecret == "" {
+			return errors.New("An auth secret is required for " + c.AuthMode + " authentication")
+		}
+	case user.AuthStatic:
+	default:
+		return errors.New("Unknown auth mode " + c.AuthMode)
+	}
+	if c.PlayTimeout <= 0 || c.JudgeTimeout <= 0 || c.ScoreTimeout <= 0 || c.EliminationTimeout <= 0 {
+		return errors.New("Stage timeouts must be positive")
+	}
+	if c.HandSize < game.MinHandSize || c.HandSize > game.MaxHandSize {
+		return fmt.Errorf("Hand size must be between %d and %d", game.MinHandSize, game.MaxHandSize)
+	}
+	if c.MinPlayers < 3 {
+		return errors.New("Min players must be at least 3")
+	}
+	if c.MaxPlayers < c.MinPlayers {
+		return errors.New("Max players must not be less than min players")
+	}
+	if c.MinBlackCards < 1 || c.MinWhiteCardsPerPlayer < 1 {
+		return errors.New("Card minimums must be positive")
+	}
+	if c.DrainTimeout < 0 || c.ShutdownTimeout < 0 {
+		return errors.New("Shutdown timeouts must not be negative")
+	}
+	if c.ReconnectGrace < 0 {
+		return errors.New("Reconnect grace period must not be negative")
+	}
+	if c.AFKStrikeLimit < 0 {
+		return errors.New("AFK strike limit must not be negative")
+	}
+	switch c.SnapshotStore {
+	case snapshot.StoreNone, snapshot.StorePostgres:
+	case snapshot.StoreFile:
+		if c.SnapshotDir == "" {
+			return errors.New("A snapshot directory is required for the file snapshot store")
+		}
+	default:
+		return errors.New("Unknown snapshot store " + c.SnapshotStore)
+	}
+	if c.SnapshotInterval <= 0 {
+		return errors.New("Snapshot interval must be positive")
+	}
+	return nil
+}
+
+// GameConfig returns the limits that apply to every game
+func (c Config) GameConfig() game.Config {
+	return game.Config{
+		PlayDuration:           time.Duration(c.PlayTimeout) * time.Second,
+		JudgeDuration:          time.Duration(c.JudgeTimeout) * time.Second,
+		ScoreDuration:          time.Duration(c.ScoreTimeout) * time.Second,
+		EliminationDuration:    time.Duration(c.EliminationTimeout) * time.Second,
+		DefaultHandSize:        c.HandSize,
+		MinPlayers:             c.MinPlayers,
+		MaxPlayers:             c.MaxPlayers,
+		MinBlackCards:          c.MinBlackCards,
+		MinWhiteCardsPerPlayer: c.MinWhiteCardsPerPlayer,
+		ReconnectGrace:         time.Duration(c.ReconnectGrace) * time.Second,
+		AFKStrikeLimit:         c.AFKStrikeLimit,
+	}
+}
+
+func (c *Config) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		// Decoded as strictly as YAML so a misspelt key is reported rather than ignored
+		d := json.NewDecoder(bytes.NewReader(b))
+		d.DisallowUnknownFields()
+		return d.Decode(c)
+	case ".yaml", ".yml":
+		return yaml.UnmarshalStrict(b, c)
+	}
+	return errors.New("Config file must be .json, .yaml or .yml")
+}
+
+// envName converts a flag name such as db-dsn to its environment variable, CARDS_DB_DSN
+func envName(flagName string) string {
+	return "CARDS_" + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+func setString(field func(c *Config) *string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		*field(c) = value
+		return nil
+	}
+}
+
+func setInt(field func(c *Config) *int) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return errors.New("Expected a whole number")
+		}
+		*field(c) = i
+		return nil
+	}
+}
+
+func setList(field func(c *Config) *[]string) func(c *Config, value string) error {
+	return func(c *Config, value string) error {
+		list := []string{}
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		*field(c) = list
+		return nil
+	}
+}
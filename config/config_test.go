@@ -0,0 +1,104 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name string, contents string) string {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", `
+databaseDsn: "dbname=from_file"
+listenAddress: ":9000"
+allowedOrigins: ["https://cards.example.com"]
+authSecret: "file secret"
+playTimeout: 90
+handSize: 7
+`)
+	os.Setenv("CARDS_LISTEN_ADDRESS", ":9100")
+	os.Setenv("CARDS_JUDGE_TIMEOUT", "45")
+	defer os.Unsetenv("CARDS_LISTEN_ADDRESS")
+	defer os.Unsetenv("CARDS_JUDGE_TIMEOUT")
+
+	c, err := Load([]string{"-config", path, "-judge-timeout", "50", "-allowed-origins", "https://a.example.com, https://b.example.com"})
+	if err != nil {
+		t.Fatalf("Failed: Could not load config - %v", err)
+	}
+	if c.DatabaseDSN != "dbname=from_file" || c.HandSize != 7 || c.PlayTimeout != 90 {
+		t.Errorf("Failed: Expected values from the config file, got %+v", c)
+	}
+	if c.ListenAddress != ":9100" {
+		t.Errorf("Failed: Expected environment to override the file, got %s", c.ListenAddress)
+	}
+	if c.JudgeTimeout != 50 {
+		t.Errorf("Failed: Expected flags to override the environment, got %d", c.JudgeTimeout)
+	}
+	if !reflect.DeepEqual(c.AllowedOrigins, []string{"https://a.example.com", "https://b.example.com"}) {
+		t.Errorf("Failed: Expected origins from flags, got %v", c.AllowedOrigins)
+	}
+	if c.ScoreTimeout != Default().ScoreTimeout {
+		t.Errorf("Failed: Expected unset values to keep their defaults")
+	}
+	if g := c.GameConfig(); g.PlayDuration != 90*time.Second || g.DefaultHandSize != 7 {
+		t.Errorf("Failed: Expected game config to use loaded values, got %+v", g)
+	}
+}
+
+func TestLoadJSON(t *testing.T) {
+	path := writeConfigFile(t, "config.json", `{"authMode": "static", "maxPlayers": 8}`)
+	c, err := Load([]string{"-config", path})
+	if err != nil || c.MaxPlayers != 8 {
+		t.Errorf("Failed: Expected max players from JSON file, got %d (%v)", c.MaxPlayers, err)
+	}
+}
+
+func TestLoadRejectsUnknownKeys(t *testing.T) {
+	files := map[string]string{
+		"config.json": `{"authMode": "static", "maxPlayer": 8}`,
+		"config.yaml": "authMode: static\nmaxPlayer: 8\n",
+	}
+	for name, contents := range files {
+		if _, err := Load([]string{"-config", writeConfigFile(t, name, contents)}); err == nil {
+			t.Errorf("Failed: Expected the misspelt key in %s to be rejected", name)
+		}
+	}
+}
+
+func TestLoadValidation(t *testing.T) {
+	invalid := [][]string{
+		{},
+		{"-auth-mode", "magic"},
+		{"-auth-secret", "s", "-hand-size", "2"},
+		{"-auth-secret", "s", "-hand-size", "21"},
+		{"-auth-secret", "s", "-min-players", "5", "-max-players", "4"},
+		{"-auth-secret", "s", "-play-timeout", "0"},
+		{"-auth-secret", "s", "-elimination-timeout", "0"},
+		{"-auth-secret", "s", "-play-timeout", "soon"},
+		{"-auth-secret", "s", "-config", "config.toml"},
+		{"-auth-secret", "s", "-snapshot-store", "redis"},
+		{"-auth-secret", "s", "-snapshot-store", "file", "-snapshot-dir", ""},
+		{"-auth-secret", "s", "-snapshot-interval", "0"},
+		{"-auth-secret", "s", "-reconnect-grace", "-1"},
+		{"-auth-secret", "s", "-afk-strike-limit", "-1"},
+	}
+	for _, args := range invalid {
+		if _, err := Load(args); err == nil {
+			t.Errorf("Failed: Expected %v to be rejected", args)
+		}
+	}
+}
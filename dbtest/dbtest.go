@@ -0,0 +1,148 @@
+// Package dbtest provides a fake database/sql driver that records every query it receives
+package dbtest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+)
+
+// Query - A statement received by the fake driver along with its bound arguments
+type Query struct {
+	SQL  string
+	Args []driver.Value
+}
+
+// Responder returns the columns and rows the fake driver should answer a query with
+type Responder func(q Query) (columns []string, rows [][]driver.Value)
+
+// Recorder collects the queries sent to a fake database
+type Recorder struct {
+	mu      sync.Mutex
+	queries []Query
+	respond Responder
+}
+
+var (
+	recordersMu sync.Mutex
+	recorders   = make(map[string]*Recorder)
+)
+
+func init() {
+	sql.Register("dbtest", fakeDriver{})
+}
+
+// Open creates a fake database that answers queries using respond (which may be nil for no rows)
+func Open(respond Responder) (*sql.DB, *Recorder) {
+	r := &Recorder{respond: respond}
+	recordersMu.Lock()
+	dsn := strconv.Itoa(len(recorders))
+	recorders[dsn] = r
+	recordersMu.Unlock()
+	db, _ := sql.Open("dbtest", dsn)
+	return db, r
+}
+
+// Queries returns every query received so far
+func (r *Recorder) Queries() []Query {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Query{}, r.queries...)
+}
+
+func (r *Recorder) record(q Query) ([]string, [][]driver.Value) {
+	r.mu.Lock()
+	r.queries = append(r.queries, q)
+	r.mu.Unlock()
+	if r.respond == nil {
+		return []string{}, nil
+	}
+	return r.respond(q)
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	recordersMu.Lock()
+	defer recordersMu.Unlock()
+	r, ok := recorders[dsn]
+	if !ok {
+		return nil, errors.New("Unknown fake database")
+	}
+	return &fakeConn{recorder: r}, nil
+}
+
+type fakeConn struct {
+	recorder *Recorder
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{recorder: c.recorder, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+// Begin starts a transaction whose statements are recorded like any other, commit and rollback do nothing
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return fakeTx{}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error {
+	return nil
+}
+
+func (fakeTx) Rollback() error {
+	return nil
+}
+
+type fakeStmt struct {
+	recorder *Recorder
+	query    string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.recorder.record(Query{SQL: s.query, Args: args})
+	return driver.RowsAffected(0), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	columns, rows := s.recorder.record(Query{SQL: s.query, Args: args})
+	return &fakeRows{columns: columns, rows: rows}, nil
+}
+
+type fakeRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	return r.columns
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
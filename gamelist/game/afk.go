@@ -0,0 +1,156 @@
+package game
+
+import (
+	"math/rand"
+
+	"../../apperror"
+	"../../card"
+	"../../server/socket"
+)
+
+// AFKMessage JSON structure for actions telling players that someone missed a deadline
+type AFKMessage struct {
+	UserID  int `json:"userId"`
+	Strikes int `json:"strikes"`
+}
+
+// JudgeReplacedMessage JSON structure for the game/JUDGE_REPLACED action
+type JudgeReplacedMessage struct {
+	OldJudgeID int `json:"oldJudgeId"`
+	NewJudgeID int `json:"newJudgeId"`
+}
+
+// Resume lets a spectating player take part again, dealing them in straight away if a round is running
+func (g *Game) Resume(pID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	i, err := g.getPlayerIndex(pID)
+	if err != nil {
+		return err
+	}
+	if !g.Players[i].spectator {
+		return apperror.InvalidState("You are not spectating")
+	}
+	g.Players[i].spectator = false
+	g.Players[i].strikes = 0
+	if g.isRunning() {
+		g.dealHand(i)
+	}
+	g.updateUserStates()
+	return nil
+}
+
+// expire handles a stage deadline passing, dealing with anyone who did not act in time before moving on
+func (g *Game) expire() {
+	switch g.stage {
+	case 1:
+		g.expirePlay()
+	case 2:
+		g.expireJudging()
+	default:
+		g.next()
+	}
+}
+
+// expirePlay strikes every player who has not finished playing, then either plays random cards for them or skips them
+func (g *Game) expirePlay() {
+	for i, p := range g.Players {
+		if p.user.ID == g.judgeID || p.spectator || g.userHasPlayed(p.user.ID) {
+			continue
+		}
+		g.Players[i].strikes++
+		msg := AFKMessage{UserID: p.user.ID, Strikes: g.Players[i].strikes}
+		if g.settings.AFKPolicy == AFKSkip {
+			g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/TURN_SKIPPED", Payload: msg})
+		} else {
+			g.autoPlay(i)
+			g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/AUTO_PLAYED", Payload: msg})
+		}
+	}
+	if g.demoteIdlePlayers() {
+		g.next()
+	}
+}
+
+// expireJudging strikes the judge for not picking a winner, then replaces them or voids the round
+func (g *Game) expireJudging() {
+	if g.anyRule(func(r houseRule) bool { return r.judgingExpired(g) }) {
+		return
+	}
+	if i, err := g.getPlayerIndex(g.judgeID); err == nil {
+		g.Players[i].strikes++
+		msg := AFKMessage{UserID: g.judgeID, Strikes: g.Players[i].strikes}
+		g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/JUDGE_IDLE", Payload: msg})
+	}
+	if !g.demoteIdlePlayers() {
+		return
+	}
+	if g.settings.IdleJudgePolicy == IdleJudgeVoid {
+		g.voidRound(g.chooseJudge(g.nextJudgeID()), "The judge did not pick a winner")
+	} else {
+		g.replaceJudge()
+	}
+}
+
+// autoPlay plays random cards from a player's hand until their submission is complete
+func (g *Game) autoPlay(i int) {
+	pID := g.Players[i].user.ID
+	for len(g.whitePlayed[pID]) < g.BlackCurrent.AnswerFields && len(g.Players[i].hand) > 0 {
+		hand := g.Players[i].hand
+		j := rand.Intn(len(hand))
+		g.whitePlayed[pID] = append(g.whitePlayed[pID], hand[j])
+		g.Players[i].hand = append(hand[:j], hand[j+1:]...)
+	}
+}
+
+// replaceJudge hands judging to the next player, whose own submission goes back into their hand
+func (g *Game) replaceJudge() {
+	oldJudgeID := g.judgeID
+	newJudgeID := g.nextJudgeID()
+	i, err := g.getPlayerIndex(newJudgeID)
+	if err != nil || newJudgeID == oldJudgeID {
+		g.voidRound(g.chooseJudge(g.nextJudgeID()), "The judge did not pick a winner")
+		return
+	}
+	g.Players[i].hand = append(g.Players[i].hand, g.whitePlayed[newJudgeID]...)
+	delete(g.whitePlayed, newJudgeID)
+	g.removeFromPlayedOrder(newJudgeID)
+	g.judgeID = newJudgeID
+	g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/JUDGE_REPLACED", Payload: JudgeReplacedMessage{OldJudgeID: oldJudgeID, NewJudgeID: newJudgeID}})
+	if len(g.whitePlayed) == 0 {
+		g.beginRound(g.chooseJudge(g.nextJudgeID()))
+		return
+	}
+	g.setStage(2, g.config.JudgeDuration)
+}
+
+// demoteIdlePlayers moves players who reached the strike limit to spectating, stopping the
+// game if too few players remain. It returns whether the game is still running.
+func (g *Game) demoteIdlePlayers() bool {
+	if g.config.AFKStrikeLimit > 0 {
+		for i, p := range g.Players {
+			if !p.spectator && p.strikes >= g.config.AFKStrikeLimit {
+				g.demote(i)
+			}
+		}
+	}
+	if g.activePlayerCount() < g.config.MinPlayers {
+		g.stop()
+		return false
+	}
+	return true
+}
+
+// demote makes a player a spectator, discarding their hand and any unfinished submission
+func (g *Game) demote(i int) {
+	pID := g.Players[i].user.ID
+	g.Players[i].spectator = true
+	g.Players[i].strikes = 0
+	g.whiteDiscard = append(g.whiteDiscard, g.Players[i].hand...)
+	g.Players[i].hand = []card.WhiteCard{}
+	if !g.userHasPlayed(pID) {
+		g.whiteDiscard = append(g.whiteDiscard, g.whitePlayed[pID]...)
+		delete(g.whitePlayed, pID)
+	}
+	g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/PLAYER_SPECTATING", Payload: pID})
+}
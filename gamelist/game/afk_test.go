@@ -0,0 +1,162 @@
+package game
+
+import (
+	"testing"
+
+	"../../card"
+	"../../user"
+)
+
+// startTestGame starts a game between the given number of players, owned by player 1
+func startTestGame(t *testing.T, settings Settings, players int, blackCards ...card.BlackCard) *Game {
+	g := createTestGame(t, settings, blackCards...)
+	for i := 1; i <= players; i++ {
+		g.Join(user.User{ID: i})
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	return g
+}
+
+// playFor completes the submission of every active player except the judge and those given
+func playFor(g *Game, except ...int) {
+	skip := map[int]bool{g.judgeID: true}
+	for _, id := range except {
+		skip[id] = true
+	}
+	for _, p := range g.Players {
+		if !skip[p.user.ID] && !p.spectator {
+			for _, c := range append([]card.WhiteCard{}, p.hand[:g.BlackCurrent.AnswerFields]...) {
+				g.PlayCard(p.user.ID, c.ID)
+			}
+		}
+	}
+}
+
+func expire(g *Game) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.expire()
+}
+
+func TestMissedPlayDeadlineAutoPlays(t *testing.T) {
+	g := startTestGame(t, Settings{}, 4)
+	defer g.Halt()
+	expire(g)
+	if g.stage != 2 {
+		t.Fatalf("Failed: Expected auto-played cards to be judged, stage is %d", g.stage)
+	}
+	for _, p := range g.Players {
+		if p.user.ID != g.judgeID && (!g.userHasPlayed(p.user.ID) || p.strikes != 1) {
+			t.Errorf("Failed: Expected player %d to have cards played for them and a strike, has %d strikes", p.user.ID, p.strikes)
+		}
+	}
+	checkInvariants(t, g, "auto play")
+}
+
+func TestMissedPlayDeadlineSkips(t *testing.T) {
+	g := startTestGame(t, Settings{AFKPolicy: AFKSkip}, 4)
+	defer g.Halt()
+	expire(g)
+	if g.stage != 1 || g.round != 2 {
+		t.Errorf("Failed: Expected a new round when everyone was skipped, stage %d round %d", g.stage, g.round)
+	}
+	checkInvariants(t, g, "skip")
+}
+
+func TestIdleJudgeIsReplaced(t *testing.T) {
+	g := startTestGame(t, Settings{}, 4)
+	defer g.Halt()
+	oldJudgeID := g.judgeID
+	playFor(g)
+	if g.stage != 2 {
+		t.Fatalf("Failed: Expected the judge phase once everyone played, stage is %d", g.stage)
+	}
+	expire(g)
+	if g.stage != 2 || g.judgeID == oldJudgeID {
+		t.Fatalf("Failed: Expected a new judge for the same submissions, judge %d stage %d", g.judgeID, g.stage)
+	}
+	if _, ok := g.whitePlayed[g.judgeID]; ok || len(g.playedOrder) != 2 {
+		t.Errorf("Failed: Expected the new judge's submission to be withdrawn")
+	}
+	if p, _ := g.getPrivatePlayer(oldJudgeID); p.strikes != 1 {
+		t.Errorf("Failed: Expected the idle judge to get a strike, has %d", p.strikes)
+	}
+	checkInvariants(t, g, "judge replacement")
+}
+
+func TestIdleJudgeVoidsRound(t *testing.T) {
+	g := startTestGame(t, Settings{IdleJudgePolicy: IdleJudgeVoid}, 4)
+	defer g.Halt()
+	playFor(g)
+	expire(g)
+	if g.stage != 1 || g.round != 2 || len(g.whitePlayed) != 0 {
+		t.Errorf("Failed: Expected the round to be voided, stage %d round %d", g.stage, g.round)
+	}
+	checkInvariants(t, g, "void")
+}
+
+func TestStrikesDemoteToSpectator(t *testing.T) {
+	g := startTestGame(t, Settings{}, 5)
+	defer g.Halt()
+	g.config.AFKStrikeLimit = 1
+	idleID := g.nextJudgeID()
+	playFor(g, idleID)
+	expire(g)
+
+	p, _ := g.getPrivatePlayer(idleID)
+	if !p.spectator || len(p.hand) != 0 {
+		t.Fatalf("Failed: Expected player %d to be spectating without a hand", idleID)
+	}
+	if g.stage != 2 || len(g.playedOrder) != 4 {
+		t.Errorf("Failed: Expected the game to continue with the idle player's auto-played submission, stage %d", g.stage)
+	}
+	if err := g.PlayCard(idleID, 1); err == nil {
+		t.Errorf("Failed: Expected spectators to be stopped from playing")
+	}
+	checkInvariants(t, g, "demotion")
+
+	if err := g.Resume(idleID); err != nil {
+		t.Fatalf("Failed: Could not resume playing - %v", err)
+	}
+	if p, _ := g.getPrivatePlayer(idleID); p.spectator || len(p.hand)+len(g.whitePlayed[idleID]) != g.settings.HandSize {
+		t.Errorf("Failed: Expected a resumed player to be dealt back in, has %d cards", len(p.hand))
+	}
+	checkInvariants(t, g, "resume")
+}
+
+func TestResumedPlayerGetsSubmissionBack(t *testing.T) {
+	for _, policy := range []string{IdleJudgeReplace, IdleJudgeVoid} {
+		g := startTestGame(t, Settings{IdleJudgePolicy: policy}, 5, pickCards(2)...)
+		g.config.AFKStrikeLimit = 1
+		idleID := g.nextJudgeID()
+		i, _ := g.getPlayerIndex(idleID)
+		spectatorID := g.nextActiveID(i)
+		playFor(g, idleID, spectatorID)
+		expire(g)
+		g.config.AFKStrikeLimit = 0
+		if err := g.Resume(idleID); err != nil {
+			t.Fatalf("Failed: Could not resume playing - %v", err)
+		}
+		expire(g)
+		if p, _ := g.getPrivatePlayer(idleID); len(p.hand) != g.settings.HandSize {
+			t.Errorf("Failed: Expected the %s policy to leave a resumed player with a full hand, has %d cards", policy, len(p.hand))
+		}
+		if p, _ := g.getPrivatePlayer(spectatorID); len(p.hand) != 0 {
+			t.Errorf("Failed: Expected the %s policy to leave a spectator without cards, has %d", policy, len(p.hand))
+		}
+		checkInvariants(t, g, policy)
+		g.Halt()
+	}
+}
+
+func TestStrikesStopGameWithTooFewPlayers(t *testing.T) {
+	g := startTestGame(t, Settings{AFKPolicy: AFKSkip}, 4)
+	g.config.AFKStrikeLimit = 1
+	expire(g)
+	if g.isRunning() || g.stage != 0 {
+		t.Errorf("Failed: Expected the game to stop when only the judge is left playing")
+	}
+	checkInvariants(t, g, "stop")
+}
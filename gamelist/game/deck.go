@@ -0,0 +1,139 @@
+package game
+
+import (
+	"fmt"
+	"strings"
+
+	"../../apperror"
+	"../../card"
+)
+
+// drawWhite takes the top card of the white draw pile, reshuffling the discards into it when it runs out
+func (g *Game) drawWhite() (card.WhiteCard, error) {
+	if len(g.whiteDraw) == 0 {
+		g.whiteDraw = g.whiteDiscard
+		g.whiteDiscard = []card.WhiteCard{}
+		card.ShuffleWhiteDeck(&g.whiteDraw)
+	}
+	if len(g.whiteDraw) == 0 {
+		return card.WhiteCard{}, apperror.InvalidState("No white cards remain")
+	}
+	c := g.whiteDraw[0]
+	g.whiteDraw = g.whiteDraw[1:]
+	return c, nil
+}
+
+// drawBlack takes the top card of the black draw pile, reshuffling the discards into it when it runs out
+func (g *Game) drawBlack() (card.BlackCard, error) {
+	if len(g.BlackDraw) == 0 {
+		g.BlackDraw = g.BlackDiscard
+		g.BlackDiscard = []card.BlackCard{}
+		card.ShuffleBlackDeck(&g.BlackDraw)
+	}
+	if len(g.BlackDraw) == 0 {
+		return card.BlackCard{}, apperror.InvalidState("No black cards remain")
+	}
+	c := g.BlackDraw[0]
+	g.BlackDraw = g.BlackDraw[1:]
+	return c, nil
+}
+
+// dealHands tops up every active player's hand to the configured hand size, replacing the cards played last round
+func (g *Game) dealHands() {
+	for i := range g.Players {
+		if !g.Players[i].spectator {
+			g.dealHand(i)
+		}
+	}
+}
+
+// dealHand tops up a single player's hand, counting any cards they still have on the table this round
+func (g *Game) dealHand(i int) {
+	for len(g.Players[i].hand)+len(g.whitePlayed[g.Players[i].user.ID]) < g.settings.HandSize {
+		c, err := g.drawWhite()
+		if err != nil {
+			return
+		}
+		g.Players[i].hand = append(g.Players[i].hand, c)
+	}
+}
+
+// replaceCards swaps cards in a player's hand for the same number from the draw pile. The new cards are drawn
+// before the old ones are discarded so that none of them can come straight back.
+func (g *Game) replaceCards(i int, cards []card.WhiteCard) {
+	replaced := make(map[int]bool)
+	for _, c := range cards {
+		replaced[c.ID] = true
+	}
+	hand := []card.WhiteCard{}
+	for _, c := range g.Players[i].hand {
+		if !replaced[c.ID] {
+			hand = append(hand, c)
+		}
+	}
+	for range cards {
+		c, err := g.drawWhite()
+		if err != nil {
+			break
+		}
+		hand = append(hand, c)
+	}
+	g.Players[i].hand = hand
+	g.whiteDiscard = append(g.whiteDiscard, cards...)
+}
+
+// CheckInvariants verifies that every card the game was created with lives in exactly one
+// of the draw piles, discard piles, player hands, played cards or the current black card
+func (g *Game) CheckInvariants() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	whiteSeen := make(map[int]int)
+	countWhite := func(cards []card.WhiteCard) {
+		for _, c := range cards {
+			whiteSeen[c.ID]++
+		}
+	}
+	countWhite(g.whiteDraw)
+	countWhite(g.whiteDiscard)
+	for _, p := range g.Players {
+		countWhite(p.hand)
+	}
+	for _, cards := range g.whitePlayed {
+		countWhite(cards)
+	}
+	if err := checkCounts("White", whiteSeen, g.whiteIDs); err != nil {
+		return err
+	}
+
+	blackSeen := make(map[int]int)
+	for _, c := range g.BlackDraw {
+		blackSeen[c.ID]++
+	}
+	for _, c := range g.BlackDiscard {
+		blackSeen[c.ID]++
+	}
+	if g.BlackCurrent != nil {
+		blackSeen[g.BlackCurrent.ID]++
+	}
+	if g.haikuCard != nil && !g.isHaikuRound() {
+		blackSeen[g.haikuCard.ID]++
+	}
+	return checkCounts("Black", blackSeen, g.blackIDs)
+}
+
+func checkCounts(color string, seen map[int]int, ids map[int]bool) error {
+	for id, n := range seen {
+		if !ids[id] {
+			return fmt.Errorf("Unknown %s card %d found in game", strings.ToLower(color), id)
+		}
+		if n > 1 {
+			return fmt.Errorf("%s card %d appears %d times", color, id, n)
+		}
+	}
+	for id := range ids {
+		if seen[id] == 0 {
+			return fmt.Errorf("%s card %d is missing", color, id)
+		}
+	}
+	return nil
+}
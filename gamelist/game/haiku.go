@@ -0,0 +1,59 @@
+package game
+
+import (
+	"strings"
+
+	"../../apperror"
+	"../../card"
+	"../../server/socket"
+)
+
+// HaikuCardID is the ID given to the haiku card when the game's cardpacks do not include one
+const HaikuCardID = -1
+
+// DeclareHappyEnding lets the owner make the next round the last, played with the haiku card (Happy Ending)
+func (g *Game) DeclareHappyEnding(uID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.haikuCard == nil {
+		return apperror.InvalidState("Happy Ending is not enabled in this game")
+	}
+	if g.ownerID != uID {
+		return apperror.Forbidden("Only the owner can end the game")
+	}
+	if !g.isRunning() {
+		return apperror.InvalidState("Game is not running")
+	}
+	if g.happyEnding || g.isHaikuRound() {
+		return apperror.InvalidState("The final round has already been called")
+	}
+	g.happyEnding = true
+	g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/HAPPY_ENDING", Payload: g.haikuCard})
+	g.updateUserStates()
+	return nil
+}
+
+// designateHaikuCard takes the "Make a haiku" card out of the black cards so it is saved for the final round,
+// creating one if the cardpacks do not have it
+func designateHaikuCard(blackCards []card.BlackCard) (card.BlackCard, []card.BlackCard) {
+	for i, c := range blackCards {
+		if strings.HasPrefix(strings.ToLower(c.Text), "make a haiku") {
+			c.AnswerFields = 3
+			return c, append(blackCards[:i:i], blackCards[i+1:]...)
+		}
+	}
+	return card.CreateBlackCard(HaikuCardID, "Make a haiku.", 3, 0), blackCards
+}
+
+// nextRoundIsFinal returns whether the round about to start must be played with the haiku card
+func (g *Game) nextRoundIsFinal() bool {
+	if g.haikuCard == nil {
+		return false
+	}
+	return g.happyEnding || (g.settings.RoundLimit > 0 && g.round+1 >= g.settings.RoundLimit)
+}
+
+// isHaikuRound returns whether the current round is the Happy Ending
+func (g *Game) isHaikuRound() bool {
+	return g.haikuCard != nil && g.BlackCurrent != nil && g.BlackCurrent.ID == g.haikuCard.ID
+}
@@ -0,0 +1,127 @@
+package game
+
+import (
+	"errors"
+	"testing"
+
+	"../../apperror"
+	"../../card"
+)
+
+// haikuCards returns pick-1 black cards including a "Make a haiku" card with ID 8
+func haikuCards() []card.BlackCard {
+	bc := pickCards(1)
+	bc[7] = card.CreateBlackCard(8, "Make a haiku.", 1, 1)
+	return bc
+}
+
+// playRound has everyone play and the judge pick the first submission, then moves on from scoring
+func playRound(t *testing.T, g *Game) {
+	playFor(g)
+	for _, cards := range g.whitePlayed {
+		if err := g.VoteCard(g.judgeID, cards[0].ID); err != nil {
+			t.Fatalf("Failed: Could not vote - %v", err)
+		}
+		break
+	}
+	g.next()
+}
+
+func blackPilesContain(g *Game, id int) bool {
+	for _, c := range append(append([]card.BlackCard{}, g.BlackDraw...), g.BlackDiscard...) {
+		if c.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestHappyEndingWithRoundLimit(t *testing.T) {
+	g := startTestGame(t, Settings{RoundLimit: 2, HouseRules: []string{RuleHappyEnding}}, 3, haikuCards()...)
+	if g.haikuCard == nil || g.haikuCard.ID != 8 || g.haikuCard.AnswerFields != 3 || blackPilesContain(g, 8) {
+		t.Fatalf("Failed: Expected the deck's haiku card to be saved as a pick-3 card, got %+v", g.haikuCard)
+	}
+	if g.isHaikuRound() || g.GetState(1).FinalRound {
+		t.Errorf("Failed: Expected the first round to use a normal black card")
+	}
+	playRound(t, g)
+	if !g.isHaikuRound() || !g.GetState(1).FinalRound {
+		t.Fatalf("Failed: Expected the last round to use the haiku card, got %+v", g.BlackCurrent)
+	}
+	checkInvariants(t, g, "haiku round")
+	playRound(t, g)
+	if g.stage != 4 {
+		t.Errorf("Failed: Expected the game to end after the haiku, stage is %d", g.stage)
+	}
+	if blackPilesContain(g, 8) || len(g.BlackDraw) != 19 {
+		t.Errorf("Failed: Expected the haiku card to be saved again rather than returned to the piles")
+	}
+	checkInvariants(t, g, "game over")
+}
+
+func TestHappyEndingAfterScoreLimit(t *testing.T) {
+	g := startTestGame(t, Settings{ScoreLimit: 1, HouseRules: []string{RuleHappyEnding}}, 3)
+	if g.haikuCard == nil || g.haikuCard.ID != HaikuCardID {
+		t.Fatalf("Failed: Expected a haiku card to be created when the deck has none")
+	}
+	playRound(t, g)
+	if !g.isHaikuRound() {
+		t.Fatalf("Failed: Expected a haiku round before the game ends, stage %d", g.stage)
+	}
+	playRound(t, g)
+	if g.stage != 4 {
+		t.Errorf("Failed: Expected the game to end after the haiku, stage is %d", g.stage)
+	}
+	checkInvariants(t, g, "game over")
+}
+
+func TestDeclareHappyEnding(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleHappyEnding}}, 3, haikuCards()...)
+	defer g.Halt()
+	if err := g.DeclareHappyEnding(2); err == nil {
+		t.Errorf("Failed: Expected only the owner to call the final round")
+	}
+	if err := g.DeclareHappyEnding(1); err != nil {
+		t.Fatalf("Failed: Could not call the final round - %v", err)
+	}
+	if err := g.DeclareHappyEnding(1); err == nil {
+		t.Errorf("Failed: Expected the final round to only be called once")
+	}
+	playRound(t, g)
+	if !g.isHaikuRound() {
+		t.Errorf("Failed: Expected the round after the call to use the haiku card")
+	}
+	checkInvariants(t, g, "called haiku round")
+}
+
+func TestNeverHaveIEver(t *testing.T) {
+	g := startTestGame(t, Settings{}, 3)
+	if err := g.DiscardCard(1, g.Players[0].hand[0].ID); err == nil {
+		t.Errorf("Failed: Expected discarding to be rejected when the rule is off")
+	}
+	g.Halt()
+
+	g = startTestGame(t, Settings{HouseRules: []string{RuleNeverHaveI}}, 3)
+	defer g.Halt()
+	c := g.Players[0].hand[0]
+	draw, discard := len(g.whiteDraw), len(g.whiteDiscard)
+	if err := g.DiscardCard(1, c.ID); err != nil {
+		t.Fatalf("Failed: Could not discard - %v", err)
+	}
+	if err := g.DiscardCard(1, c.ID); err == nil {
+		t.Errorf("Failed: Expected a card that is no longer in hand to be rejected")
+	}
+	p, _ := g.getPrivatePlayer(1)
+	if len(p.hand) != g.settings.HandSize {
+		t.Errorf("Failed: Expected a replacement card, hand has %d cards", len(p.hand))
+	}
+	if len(g.whiteDraw) != draw-1 || len(g.whiteDiscard) != discard+1 || g.whiteDiscard[len(g.whiteDiscard)-1].ID != c.ID {
+		t.Errorf("Failed: Expected the card in the discard pile and its replacement from the draw pile")
+	}
+	checkInvariants(t, g, "discard")
+
+	g.Stop(g.ownerID)
+	if err := g.DiscardCard(1, p.hand[0].ID); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected discarding to be rejected once the game has stopped, got %v", err)
+	}
+}
@@ -0,0 +1,408 @@
+package game
+
+import (
+	"../../apperror"
+	"../../card"
+	"../../server/socket"
+	"../../user"
+)
+
+// House rules
+const (
+	RuleRando       = "rando"
+	RulePackingHeat = "packingHeat"
+	RuleReboot      = "rebootingTheUniverse"
+	RuleGodIsDead   = "godIsDead"
+	RuleSurvival    = "survivalOfTheFittest"
+	RuleHappyEnding = "happyEnding"
+	RuleNeverHaveI  = "neverHaveIEver"
+)
+
+// RandoID is the user ID used for Rando Cardrissian's submissions
+const RandoID = -1
+
+// houseRule - An optional rule that changes how a game plays. Rules keep no state of their own,
+// anything they need to remember lives on the game so it is reset and snapshotted with everything else.
+type houseRule interface {
+	// created is called once when the game is created, before any cards are dealt
+	created(g *Game)
+	// nextBlackCard returns the black card the next round must be played with, or nil to draw one
+	nextBlackCard(g *Game) *card.BlackCard
+	// keepsBlackCard returns whether the rule holds on to a black card once its round is over
+	keepsBlackCard(g *Game, c card.BlackCard) bool
+	// roundStarted is called once the black card is drawn and hands are dealt
+	roundStarted(g *Game)
+	// cardsPlayed is called when card play ends, before submissions are judged
+	cardsPlayed(g *Game)
+	// scored is called when the submission from winnerID wins the round
+	scored(g *Game, winnerID int)
+	// players returns the phantom players the rule adds to the table
+	players(g *Game) []Player
+	// judges returns whether the rule picks the winners itself, so rounds are played without a judge
+	judges() bool
+	// judgingStarted is called when submissions are ready to be judged, returning whether the rule set the stage
+	judgingStarted(g *Game) bool
+	// voted is called when a player picks a submission during judging, returning whether the rule handled it
+	voted(g *Game, pID int, cardID int) (bool, error)
+	// judgingExpired is called when the judge phase deadline passes, returning whether the rule handled it
+	judgingExpired(g *Game) bool
+	// playerLeft is called when a player leaves during judging, returning whether the rule moved the game on
+	playerLeft(g *Game, pID int) bool
+	// addState adds anything the rule shows players to their view of the game
+	addState(g *Game, pID int, s *UserState)
+	// endsGame returns whether the rule ends the game after this round
+	endsGame(g *Game) bool
+	// extraRound is called once the game would end, returning whether the rule plays one more round first
+	extraRound(g *Game) bool
+}
+
+// baseRule - Hooks that change nothing, embedded by rules so they only implement the ones they need
+type baseRule struct{}
+
+func (baseRule) created(g *Game) {}
+
+func (baseRule) nextBlackCard(g *Game) *card.BlackCard {
+	return nil
+}
+
+func (baseRule) keepsBlackCard(g *Game, c card.BlackCard) bool {
+	return false
+}
+
+func (baseRule) roundStarted(g *Game) {}
+
+func (baseRule) cardsPlayed(g *Game) {}
+
+func (baseRule) scored(g *Game, winnerID int) {}
+
+func (baseRule) players(g *Game) []Player {
+	return nil
+}
+
+func (baseRule) judges() bool {
+	return false
+}
+
+func (baseRule) judgingStarted(g *Game) bool {
+	return false
+}
+
+func (baseRule) voted(g *Game, pID int, cardID int) (bool, error) {
+	return false, nil
+}
+
+func (baseRule) judgingExpired(g *Game) bool {
+	return false
+}
+
+func (baseRule) playerLeft(g *Game, pID int) bool {
+	return false
+}
+
+func (baseRule) addState(g *Game, pID int, s *UserState) {}
+
+func (baseRule) endsGame(g *Game) bool {
+	return false
+}
+
+func (baseRule) extraRound(g *Game) bool {
+	return false
+}
+
+var houseRules = map[string]houseRule{
+	RuleRando:       rando{},
+	RulePackingHeat: packingHeat{},
+	RuleReboot:      reboot{},
+	RuleGodIsDead:   godIsDead{},
+	RuleSurvival:    survival{},
+	RuleHappyEnding: happyEnding{},
+	RuleNeverHaveI:  neverHaveIEver{},
+}
+
+// validateHouseRules checks that every rule exists and is only given once
+func validateHouseRules(names []string) error {
+	seen := make(map[string]bool)
+	for _, name := range names {
+		if _, ok := houseRules[name]; !ok {
+			return apperror.Validation("Unknown house rule " + name)
+		}
+		if seen[name] {
+			return apperror.Validation("House rule " + name + " is given more than once")
+		}
+		seen[name] = true
+	}
+	if seen[RuleGodIsDead] && seen[RuleSurvival] {
+		return apperror.Validation("God Is Dead and Survival of the Fittest cannot be combined")
+	}
+	return nil
+}
+
+// getHouseRules looks up the rules named in the settings, which must already be validated
+func getHouseRules(names []string) []houseRule {
+	rules := []houseRule{}
+	for _, name := range names {
+		rules = append(rules, houseRules[name])
+	}
+	return rules
+}
+
+func (g *Game) hasHouseRule(name string) bool {
+	for _, n := range g.settings.HouseRules {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// anyRule calls f with each house rule until one returns true, returning whether any did
+func (g *Game) anyRule(f func(r houseRule) bool) bool {
+	for _, r := range g.rules {
+		if f(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasJudge returns whether rounds are judged by a player rather than by a house rule
+func (g *Game) hasJudge() bool {
+	return !g.anyRule(func(r houseRule) bool { return r.judges() })
+}
+
+// RebootHand lets a player trade in a point to discard their hand and draw a new one (Rebooting the Universe)
+func (g *Game) RebootHand(pID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.hasHouseRule(RuleReboot) {
+		return apperror.InvalidState("Rebooting the Universe is not enabled in this game")
+	}
+	if !g.isRunning() {
+		return apperror.InvalidState("Hands can only be rebooted while the game is running")
+	}
+	i, err := g.getPlayerIndex(pID)
+	if err != nil {
+		return err
+	}
+	if g.Players[i].spectator {
+		return apperror.Forbidden("Spectators cannot reboot their hand")
+	}
+	if g.Players[i].score < 1 {
+		return apperror.InvalidState("You need a point to reboot your hand")
+	}
+	// Cards from an unfinished submission would come back on top of the new hand
+	if g.stage == 1 && len(g.whitePlayed[pID]) > 0 {
+		return apperror.InvalidState("You cannot reboot your hand after playing cards this round")
+	}
+	g.replaceCards(i, g.Players[i].hand)
+	g.Players[i].score--
+	g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/HAND_REBOOTED", Payload: pID})
+	g.updateUserStates()
+	return nil
+}
+
+// DiscardMessage JSON structure for the game/CARD_DISCARDED action
+type DiscardMessage struct {
+	UserID int            `json:"userId"`
+	Card   card.WhiteCard `json:"card"`
+}
+
+// DiscardCard lets a player throw away a card they do not understand and draw another, as long as they
+// admit it to the table (Never Have I Ever)
+func (g *Game) DiscardCard(pID int, cardID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.hasHouseRule(RuleNeverHaveI) {
+		return apperror.InvalidState("Never Have I Ever is not enabled in this game")
+	}
+	if !g.isRunning() {
+		return apperror.InvalidState("Cards can only be discarded while the game is running")
+	}
+	i, err := g.getPlayerIndex(pID)
+	if err != nil {
+		return err
+	}
+	if g.Players[i].spectator {
+		return apperror.Forbidden("Spectators cannot discard cards")
+	}
+	for _, c := range g.Players[i].hand {
+		if c.ID == cardID {
+			g.replaceCards(i, []card.WhiteCard{c})
+			g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/CARD_DISCARDED", Payload: DiscardMessage{UserID: pID, Card: c}})
+			g.updateUserStates()
+			return nil
+		}
+	}
+	return apperror.Validation("Card is not in your hand")
+}
+
+// rando - Rando Cardrissian, a phantom player who plays random white cards every round
+type rando struct{ baseRule }
+
+func (rando) roundStarted(g *Game) {
+	for len(g.whitePlayed[RandoID]) < g.BlackCurrent.AnswerFields {
+		c, err := g.drawWhite()
+		if err != nil {
+			return
+		}
+		g.whitePlayed[RandoID] = append(g.whitePlayed[RandoID], c)
+	}
+}
+
+func (rando) scored(g *Game, winnerID int) {
+	if winnerID == RandoID {
+		g.phantomScores[RandoID]++
+	}
+}
+
+func (rando) players(g *Game) []Player {
+	return []Player{{
+		User:      user.User{ID: RandoID, Name: "Rando Cardrissian"},
+		Score:     g.phantomScores[RandoID],
+		HasPlayed: g.userHasPlayed(RandoID),
+		Connected: true,
+		Presence:  PresenceConnected,
+		Human:     false,
+	}}
+}
+
+// packingHeat - Packing Heat, players draw an extra white card before answering a pick-2 black card
+type packingHeat struct{ baseRule }
+
+func (packingHeat) roundStarted(g *Game) {
+	if g.BlackCurrent.AnswerFields != 2 {
+		return
+	}
+	for i, p := range g.Players {
+		// Only top up to one extra card so hands never keep growing
+		if p.user.ID != g.judgeID && !p.spectator && len(p.hand) <= g.settings.HandSize {
+			c, err := g.drawWhite()
+			if err != nil {
+				return
+			}
+			g.Players[i].hand = append(g.Players[i].hand, c)
+		}
+	}
+}
+
+// reboot - Rebooting the Universe, which only enables the RebootHand action
+type reboot struct{ baseRule }
+
+// godIsDead - God Is Dead, there is no judge and everyone votes for their favourite submission
+type godIsDead struct{ baseRule }
+
+func (godIsDead) judges() bool {
+	return true
+}
+
+func (godIsDead) voted(g *Game, pID int, cardID int) (bool, error) {
+	return true, g.castVote(pID, cardID)
+}
+
+func (godIsDead) judgingExpired(g *Game) bool {
+	g.expireVoting()
+	return true
+}
+
+func (godIsDead) playerLeft(g *Game, pID int) bool {
+	g.removeVotes(pID)
+	if !g.allVotesIn() {
+		return false
+	}
+	g.tallyVotes()
+	g.next()
+	return true
+}
+
+func (godIsDead) addState(g *Game, pID int, s *UserState) {
+	if g.stage == 3 {
+		s.VoteTally = g.getVoteTally()
+	}
+}
+
+// survival - Survival of the Fittest, there is no judge and players take turns eliminating submissions until one remains
+type survival struct{ baseRule }
+
+func (survival) judges() bool {
+	return true
+}
+
+func (survival) judgingStarted(g *Game) bool {
+	g.startElimination()
+	return true
+}
+
+func (survival) voted(g *Game, pID int, cardID int) (bool, error) {
+	return true, g.eliminate(pID, cardID)
+}
+
+func (survival) judgingExpired(g *Game) bool {
+	g.expireElimination()
+	return true
+}
+
+func (survival) playerLeft(g *Game, pID int) bool {
+	if len(g.remainingSubmissions()) > 1 {
+		return false
+	}
+	g.finishElimination()
+	return true
+}
+
+func (survival) addState(g *Game, pID int, s *UserState) {
+	if g.stage != 2 {
+		return
+	}
+	// Eliminated submissions are shown face up on their own rather than left in the running
+	s.WhiteCardsUnknown = [][]card.WhiteCard{}
+	for _, id := range g.remainingSubmissions() {
+		if id != pID {
+			s.WhiteCardsUnknown = append(s.WhiteCardsUnknown, g.whitePlayed[id])
+		}
+	}
+	s.EliminatedCards = g.getEliminatedCards()
+	s.EliminatorID = g.eliminatorID()
+}
+
+// happyEnding - Happy Ending, the final round is always played with the "Make a haiku" black card
+type happyEnding struct{ baseRule }
+
+func (happyEnding) created(g *Game) {
+	haiku, rest := designateHaikuCard(g.BlackDraw)
+	g.haikuCard = &haiku
+	g.BlackDraw = rest
+	g.blackIDs[haiku.ID] = true
+}
+
+func (happyEnding) nextBlackCard(g *Game) *card.BlackCard {
+	if !g.nextRoundIsFinal() {
+		return nil
+	}
+	haiku := *g.haikuCard
+	return &haiku
+}
+
+// keepsBlackCard saves the haiku card for the final round rather than letting it go into the deck
+func (happyEnding) keepsBlackCard(g *Game, c card.BlackCard) bool {
+	return c.ID == g.haikuCard.ID
+}
+
+func (happyEnding) addState(g *Game, pID int, s *UserState) {
+	s.FinalRound = g.happyEnding || g.isHaikuRound()
+}
+
+func (happyEnding) endsGame(g *Game) bool {
+	return g.isHaikuRound()
+}
+
+// extraRound makes sure the game always finishes with a haiku
+func (happyEnding) extraRound(g *Game) bool {
+	if g.isHaikuRound() {
+		return false
+	}
+	g.happyEnding = true
+	return true
+}
+
+// neverHaveIEver - Never Have I Ever, which only enables the DiscardCard action
+type neverHaveIEver struct{ baseRule }
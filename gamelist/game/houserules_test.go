@@ -0,0 +1,137 @@
+package game
+
+import (
+	"testing"
+
+	"../../card"
+	"../../server/socket"
+)
+
+func TestUnknownHouseRuleIsRejected(t *testing.T) {
+	settings := Settings{HouseRules: []string{"free parking"}}
+	if _, err := CreateGame("Test", 10, settings, DefaultConfig(), []card.WhiteCard{}, []card.BlackCard{}, socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected an unknown house rule to be rejected")
+	}
+	settings = Settings{HouseRules: []string{RuleRando, RuleRando}}
+	if _, err := CreateGame("Test", 10, settings, DefaultConfig(), []card.WhiteCard{}, []card.BlackCard{}, socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a repeated house rule to be rejected")
+	}
+}
+
+func TestRandoCardrissian(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleRando}, ScoreLimit: 2}, 3)
+	defer g.Halt()
+
+	for round := 1; round <= 2; round++ {
+		if !g.userHasPlayed(RandoID) {
+			t.Fatalf("Failed: Expected Rando to play at the start of round %d", round)
+		}
+		checkInvariants(t, g, "Rando playing")
+		playFor(g)
+		if len(g.playedOrder) != 3 {
+			t.Fatalf("Failed: Expected Rando's submission to be judged alongside 2 players, got %d", len(g.playedOrder))
+		}
+		if err := g.VoteCard(g.judgeID, g.whitePlayed[RandoID][0].ID); err != nil {
+			t.Fatalf("Failed: Could not vote for Rando - %v", err)
+		}
+		g.next()
+	}
+
+	if g.stage != 4 {
+		t.Errorf("Failed: Expected Rando reaching the score limit to end the game, stage is %d", g.stage)
+	}
+	standings := g.getStandings()
+	if standings[0].User.ID != RandoID || standings[0].Human || standings[0].Score != 2 {
+		t.Errorf("Failed: Expected Rando to top the standings as a non-human player, got %+v", standings[0])
+	}
+	for _, p := range standings[1:] {
+		if !p.Human || p.Score != 0 {
+			t.Errorf("Failed: Expected player %d to be human without points, got %+v", p.User.ID, p)
+		}
+	}
+	checkInvariants(t, g, "game over")
+}
+
+func TestPackingHeat(t *testing.T) {
+	for pick := 1; pick <= 3; pick++ {
+		g := startTestGame(t, Settings{HouseRules: []string{RulePackingHeat}}, 4, pickCards(pick)...)
+		for round := 1; round <= 3; round++ {
+			for _, p := range g.Players {
+				expected := g.settings.HandSize
+				if pick == 2 && p.user.ID != g.judgeID {
+					expected++
+				}
+				if len(p.hand) != expected {
+					t.Errorf("Failed: Expected player %d to hold %d cards for a pick-%d card in round %d, has %d", p.user.ID, expected, pick, round, len(p.hand))
+				}
+			}
+			checkInvariants(t, g, "Packing Heat")
+			playFor(g)
+			g.next()
+			g.next()
+		}
+		g.Halt()
+	}
+}
+
+func TestRebootingTheUniverse(t *testing.T) {
+	g := startTestGame(t, Settings{}, 3)
+	g.Players[0].score = 1
+	if err := g.RebootHand(1); err == nil {
+		t.Errorf("Failed: Expected rebooting to be rejected when the rule is off")
+	}
+	g.Halt()
+
+	g = startTestGame(t, Settings{HouseRules: []string{RuleReboot}}, 3)
+	defer g.Halt()
+	if err := g.RebootHand(1); err == nil {
+		t.Errorf("Failed: Expected rebooting to cost a point")
+	}
+	g.Players[0].score = 1
+	old := map[int]bool{}
+	for _, c := range g.Players[0].hand {
+		old[c.ID] = true
+	}
+	discarded := len(g.whiteDiscard)
+	if err := g.RebootHand(1); err != nil {
+		t.Fatalf("Failed: Could not reboot hand - %v", err)
+	}
+	p, _ := g.getPrivatePlayer(1)
+	if p.score != 0 || len(p.hand) != g.settings.HandSize {
+		t.Errorf("Failed: Expected a full hand for one point, got %d cards and %d points", len(p.hand), p.score)
+	}
+	for _, c := range p.hand {
+		if old[c.ID] {
+			t.Errorf("Failed: Expected card %d from the old hand to be discarded", c.ID)
+		}
+	}
+	if len(g.whiteDiscard) != discarded+len(old) {
+		t.Errorf("Failed: Expected the old hand in the discard pile, found %d cards", len(g.whiteDiscard)-discarded)
+	}
+	checkInvariants(t, g, "reboot")
+}
+
+func TestRebootKeepsHandSize(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleReboot, RulePackingHeat}}, 4, pickCards(2)...)
+	defer g.Halt()
+	pID := g.nextJudgeID()
+	i, _ := g.getPlayerIndex(pID)
+	g.Players[i].score = 2
+	if err := g.RebootHand(pID); err != nil {
+		t.Fatalf("Failed: Could not reboot hand - %v", err)
+	}
+	if n := len(g.Players[i].hand); n != g.settings.HandSize+1 {
+		t.Errorf("Failed: Expected the Packing Heat card to be kept after rebooting, has %d cards", n)
+	}
+	if err := g.PlayCard(pID, g.Players[i].hand[0].ID); err != nil {
+		t.Fatalf("Failed: Could not play card - %v", err)
+	}
+	if err := g.RebootHand(pID); err == nil {
+		t.Errorf("Failed: Expected rebooting after playing part of a submission to be rejected")
+	}
+	expire(g)
+	if p, _ := g.getPrivatePlayer(pID); len(p.hand) > g.settings.HandSize+1 {
+		t.Errorf("Failed: Expected the hand not to grow past its size, has %d cards", len(p.hand))
+	}
+	checkInvariants(t, g, "reboot")
+}
@@ -6,33 +6,56 @@ package game
 // 1. Card play phase
 // 2. Judge phase
 // 3. Scoring phase
+// 4. Game over
 
 import (
-	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
 	"time"
 
+	"../../apperror"
 	"../../card"
 	"../../server/socket"
 	"../../user"
 )
 
-// Game - A cards game
+// Game - A cards game, safe for concurrent use
 type Game struct {
-	Name          string
-	MaxPlayers    int
-	Players       []player
-	ownerID       int
-	judgeID       int
-	stage         int
-	nextStage     *time.Time
-	socketHandler *socket.Handler
-	timer         *time.Timer
-	whiteDraw     []card.WhiteCard
-	whiteDiscard  []card.WhiteCard
-	whitePlayed   map[int][]card.WhiteCard // Maps user IDs to an array of cards they played this round
-	BlackDraw     []card.BlackCard
-	BlackDiscard  []card.BlackCard
-	BlackCurrent  *card.BlackCard
+	mu               sync.Mutex
+	Name             string
+	MaxPlayers       int
+	Players          []player
+	config           Config
+	settings         Settings
+	ownerID          int
+	judgeID          int
+	stage            int
+	round            int
+	startedAt        time.Time
+	nextStage        *time.Time
+	socketHandler    *socket.Handler
+	timer            *time.Timer
+	timerID          int // Incremented whenever the timer is replaced so stale callbacks can be ignored
+	whiteDraw        []card.WhiteCard
+	whiteDiscard     []card.WhiteCard
+	whitePlayed      map[int][]card.WhiteCard // Maps user IDs to an array of cards they played this round
+	playedOrder      []int                    // Anonymised order in which submissions are shown during judging
+	roundWinnerIDs   []int                    // Everyone who won this round, more than one when a tie is shared
+	BlackDraw        []card.BlackCard
+	BlackDiscard     []card.BlackCard
+	BlackCurrent     *card.BlackCard
+	whiteIDs         map[int]bool // Every white card the game was created with
+	blackIDs         map[int]bool // Every black card the game was created with
+	rules            []houseRule
+	phantomScores    map[int]int // Scores of phantom players added by house rules
+	votes            map[int]int // Maps voters to the player whose submission they voted for (God Is Dead)
+	eliminated       []int       // Owners of submissions knocked out this round, in order (Survival of the Fittest)
+	eliminationOrder []int       // Players taking turns to eliminate submissions
+	eliminationTurn  int
+	haikuCard        *card.BlackCard // Saved for the final round (Happy Ending)
+	happyEnding      bool            // Whether the next round has been called as the last
 }
 
 // UserState - The state of a game for a particular user
@@ -41,6 +64,12 @@ type UserState struct {
 	BlackCard         *card.BlackCard          `json:"blackCard"`
 	WhiteCardsUnknown [][]card.WhiteCard       `json:"whiteCardsUnknown,omitempty"`
 	WhiteCardsKnown   map[int][]card.WhiteCard `json:"whiteCardsKnown,omitempty"`
+	RoundWinnerIDs    []int                    `json:"roundWinnerIds,omitempty"`  // Every winner when a tie is shared
+	VoteTally         map[int]int              `json:"voteTally,omitempty"`       // Votes per submission in God Is Dead games, shown when scoring
+	EliminatedCards   [][]card.WhiteCard       `json:"eliminatedCards,omitempty"` // Submissions knocked out so far in Survival of the Fittest games
+	EliminatorID      int                      `json:"eliminatorId,omitempty"`    // Whose turn it is to eliminate a submission
+	FinalRound        bool                     `json:"finalRound,omitempty"`      // Whether this round or the next is the Happy Ending
+	WinningCards      []card.WhiteCard         `json:"winningCards,omitempty"`
 	JudgeID           int                      `json:"judgeId,omitempty"`
 	OwnerID           int                      `json:"ownerId"`
 	Players           []Player                 `json:"players"`
@@ -56,45 +85,69 @@ type GenericState struct {
 }
 
 // CreateGame .
-func CreateGame(name string, maxPlayers int, whiteCards []card.WhiteCard, blackCards []card.BlackCard, socketHandler *socket.Handler) (*Game, error) {
+func CreateGame(name string, maxPlayers int, settings Settings, config Config, whiteCards []card.WhiteCard, blackCards []card.BlackCard, socketHandler *socket.Handler) (*Game, error) {
 	if len(name) > 64 {
-		return &Game{}, errors.New("Game name must not exceed 64 characters")
+		return &Game{}, apperror.Validation("Game name must not exceed 64 characters")
 	}
-	// TODO - Get min black card count from config file instead of hardcoding to 10
-	if len(blackCards) < 10 {
-		return &Game{}, errors.New("Insufficient number of black cards")
+	if err := settings.validate(config); err != nil {
+		return &Game{}, err
 	}
-	// TODO - Get min white card count from config file instead of hardcoding
-	if len(whiteCards) < (maxPlayers * 10) {
-		return &Game{}, errors.New("Insufficient number of white cards")
+	if len(blackCards) < config.MinBlackCards {
+		return &Game{}, apperror.Validation("Insufficient number of black cards")
 	}
-	if maxPlayers < 3 {
-		return &Game{}, errors.New("Max players must be at least 3")
+	if len(whiteCards) < maxPlayers*config.MinWhiteCardsPerPlayer || len(whiteCards) < maxPlayers*settings.HandSize {
+		return &Game{}, apperror.Validation("Insufficient number of white cards")
 	}
-	if maxPlayers > 20 {
-		return &Game{}, errors.New("Max players must not exceed 20")
+	if maxPlayers < config.MinPlayers {
+		return &Game{}, apperror.Validation(fmt.Sprintf("Max players must be at least %d", config.MinPlayers))
+	}
+	if maxPlayers > config.MaxPlayers {
+		return &Game{}, apperror.Validation(fmt.Sprintf("Max players must not exceed %d", config.MaxPlayers))
 	}
 	game := Game{
 		Name:          name,
 		MaxPlayers:    maxPlayers,
+		config:        config,
+		settings:      settings,
 		socketHandler: socketHandler,
 		whiteDraw:     whiteCards,
+		whitePlayed:   make(map[int][]card.WhiteCard),
 		BlackDraw:     blackCards,
+		whiteIDs:      make(map[int]bool),
+		blackIDs:      make(map[int]bool),
+		rules:         getHouseRules(settings.HouseRules),
+		phantomScores: make(map[int]int),
+		votes:         make(map[int]int),
+	}
+	for _, c := range whiteCards {
+		game.whiteIDs[c.ID] = true
+	}
+	for _, c := range blackCards {
+		game.blackIDs[c.ID] = true
+	}
+	for _, r := range game.rules {
+		r.created(&game)
 	}
 	return &game, nil
 }
 
 // GetState returns the game state for a particular user (will return generic game state if user is not in the game)
 func (g *Game) GetState(pID int) UserState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.getState(pID)
+}
+
+func (g *Game) getState(pID int) UserState {
 	player, _ := g.getPrivatePlayer(pID)
 	knownCards := make(map[int][]card.WhiteCard)
 	unknownCards := [][]card.WhiteCard{}
 
 	knownCards[pID] = g.whitePlayed[pID]
 	if g.stage == 2 {
-		for id, c := range g.whitePlayed {
+		for _, id := range g.playedOrder {
 			if id != pID {
-				unknownCards = append(unknownCards, c)
+				unknownCards = append(unknownCards, g.whitePlayed[id])
 			}
 		}
 	} else if g.stage == 3 {
@@ -103,11 +156,13 @@ func (g *Game) GetState(pID int) UserState {
 		}
 	}
 
-	return UserState{
+	state := UserState{
 		Name:              g.Name,
 		BlackCard:         g.BlackCurrent,
 		WhiteCardsUnknown: unknownCards,
 		WhiteCardsKnown:   knownCards,
+		RoundWinnerIDs:    g.roundWinnerIDs,
+		WinningCards:      g.whitePlayed[g.roundWinnerID()],
 		JudgeID:           g.judgeID,
 		OwnerID:           g.ownerID,
 		Players:           g.getPublicPlayers(),
@@ -115,27 +170,50 @@ func (g *Game) GetState(pID int) UserState {
 		CurrentStage:      g.stage,
 		NextStage:         g.nextStage,
 	}
+	for _, r := range g.rules {
+		r.addState(g, pID, &state)
+	}
+	return state
 }
 
 // Start .
 func (g *Game) Start(uID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.ownerID != uID {
-		return errors.New("Only the owner can start the game")
+		return apperror.Forbidden("Only the owner can start the game")
 	}
 	if g.isRunning() {
-		return errors.New("Game is already running")
+		return apperror.InvalidState("Game is already running")
+	}
+	if g.activePlayerCount() < g.config.MinPlayers {
+		return apperror.InvalidState("Not enough players to start the game")
 	}
+	if g.stage == 4 {
+		// Rematch with the same players
+		for i := range g.Players {
+			g.Players[i].score = 0
+		}
+		g.phantomScores = make(map[int]int)
+		g.stage = 0
+	}
+	g.round = 0
+	g.startedAt = time.Now()
+	card.ShuffleWhiteDeck(&g.whiteDraw)
+	card.ShuffleBlackDeck(&g.BlackDraw)
 	g.next()
 	return nil
 }
 
 // Stop .
 func (g *Game) Stop(uID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if g.ownerID != uID {
-		return errors.New("Only the owner can stop the game")
+		return apperror.Forbidden("Only the owner can stop the game")
 	}
 	if !g.isRunning() {
-		return errors.New("Game is not running")
+		return apperror.InvalidState("Game is not running")
 	}
 	g.stop()
 	return nil
@@ -143,57 +221,232 @@ func (g *Game) Stop(uID int) error {
 
 // Join .
 func (g *Game) Join(u user.User) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	if !g.playerIsInGame(u.ID) {
-		g.Players = append(g.Players, player{user: u, hand: []card.WhiteCard{}, score: 0})
+		g.Players = append(g.Players, player{user: u, hand: []card.WhiteCard{}, score: 0, presence: PresenceConnected})
 		if len(g.Players) == 1 {
 			g.ownerID = u.ID
 		}
+		// Players joining mid-round are dealt in straight away so they can play this round
+		if g.isRunning() {
+			g.dealHand(len(g.Players) - 1)
+		}
 	}
 	g.updateUserStates()
 }
 
 // Leave .
 func (g *Game) Leave(pID int) {
-	for i, p := range g.Players {
-		if p.user.ID == pID {
-			g.Players = append(g.Players[:i], g.Players[i+1:]...)
-			if pID == g.ownerID {
-				if len(g.Players) == 0 {
-					g.ownerID = 0
-				} else {
-					g.ownerID = g.Players[0].user.ID
-				}
-			}
-			if pID == g.judgeID {
-				// TODO - Properly reassign judge
-			}
-			if len(g.Players) < 4 && g.isRunning() {
-				g.stop()
-			}
-			break
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.leave(pID)
+}
+
+// IsRunning returns whether a round is in progress
+func (g *Game) IsRunning() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.isRunning()
+}
+
+// Halt stops the stage timer without touching any other state, leaving the game frozen for shutdown
+func (g *Game) Halt() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.halt()
+}
+
+// HaltBetweenRounds halts the game unless a round is being played or judged, returning whether it was halted
+func (g *Game) HaltBetweenRounds() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stage == 1 || g.stage == 2 {
+		return false
+	}
+	g.halt()
+	return true
+}
+
+func (g *Game) halt() {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.timerID++
+}
+
+// SetPresence records whether a player is connected, away or disconnected and tells the other players
+func (g *Game) SetPresence(pID int, presence string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	switch presence {
+	case PresenceConnected, PresenceAway, PresenceDisconnected:
+	default:
+		return apperror.Validation("Unknown presence " + presence)
+	}
+	i, err := g.getPlayerIndex(pID)
+	if err != nil {
+		return err
+	}
+	if g.Players[i].presence != presence {
+		g.Players[i].presence = presence
+		g.updateUserStates()
+	}
+	return nil
+}
+
+// PlayerCount returns the number of players in the game
+func (g *Game) PlayerCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.Players)
+}
+
+func (g *Game) leave(pID int) {
+	i, err := g.getPlayerIndex(pID)
+	if err != nil {
+		g.updateUserStates()
+		return
+	}
+	successorID := g.nextActiveID(i)
+
+	g.whiteDiscard = append(g.whiteDiscard, g.Players[i].hand...)
+	g.whiteDiscard = append(g.whiteDiscard, g.whitePlayed[pID]...)
+	delete(g.whitePlayed, pID)
+	g.removeFromPlayedOrder(pID)
+	g.Players = append(g.Players[:i], g.Players[i+1:]...)
+
+	if pID == g.ownerID {
+		if len(g.Players) == 0 {
+			g.ownerID = 0
+		} else {
+			g.ownerID = g.Players[0].user.ID
 		}
 	}
-	g.updateUserStates()
+
+	if !g.isRunning() {
+		g.updateUserStates()
+	} else if g.activePlayerCount() < g.config.MinPlayers {
+		g.stop()
+	} else if pID == g.judgeID {
+		g.voidRound(g.chooseJudge(successorID), "The judge left the game")
+	} else if g.stage == 1 && g.allPlayersHavePlayed() {
+		g.next()
+	} else if g.stage == 2 && len(g.whitePlayed) == 0 {
+		g.beginRound(g.chooseJudge(g.nextJudgeID()))
+	} else if g.stage != 2 || !g.anyRule(func(r houseRule) bool { return r.playerLeft(g, pID) }) {
+		g.updateUserStates()
+	}
 }
 
 // KickUser allows the game owner to boot users from the game
-func (g *Game) KickUser(ownerID int, userID int) {
-	if ownerID == g.ownerID && ownerID != userID {
-		g.Leave(userID)
+func (g *Game) KickUser(ownerID int, userID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if ownerID != g.ownerID {
+		return apperror.Forbidden("Only the owner can kick players")
+	}
+	if ownerID == userID {
+		return apperror.Validation("You cannot kick yourself")
 	}
+	if !g.playerIsInGame(userID) {
+		return apperror.NotFound("User is not in this game")
+	}
+	g.leave(userID)
+	return nil
 }
 
-// PlayCard .
+// PlayCard moves a card from a player's hand into their submission for the current round
 func (g *Game) PlayCard(pID int, cID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stage != 1 {
+		return apperror.InvalidState("Cards can only be played during the card play phase")
+	}
+	if pID == g.judgeID {
+		return apperror.Forbidden("The judge cannot play cards")
+	}
+	i, err := g.getPlayerIndex(pID)
+	if err != nil {
+		return err
+	}
+	if g.Players[i].spectator {
+		return apperror.Forbidden("Spectators cannot play cards")
+	}
+	if len(g.whitePlayed[pID]) >= g.BlackCurrent.AnswerFields {
+		return apperror.InvalidState("You have already played all of your cards this round")
+	}
+	hand := g.Players[i].hand
+	for j, c := range hand {
+		if c.ID == cID {
+			g.Players[i].hand = append(hand[:j], hand[j+1:]...)
+			g.Players[i].strikes = 0
+			g.whitePlayed[pID] = append(g.whitePlayed[pID], c)
+			if g.allPlayersHavePlayed() {
+				g.next()
+			} else {
+				g.updateUserStates()
+			}
+			return nil
+		}
+	}
+	return apperror.Validation("Card is not in your hand")
+}
+
+// VoteCard allows the game judge to pick their favorite card, awarding a point to whoever played it
+func (g *Game) VoteCard(judgeID int, cardID int) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stage != 2 {
+		return apperror.InvalidState("Cards can only be voted on during the judge phase")
+	}
+	for _, r := range g.rules {
+		if handled, err := r.voted(g, judgeID, cardID); handled {
+			return err
+		}
+	}
+	if judgeID != g.judgeID {
+		return apperror.Forbidden("Only the judge can vote")
+	}
+	id, ok := g.getSubmissionOwner(cardID)
+	if !ok {
+		return apperror.Validation("Card was not played this round")
+	}
+	g.roundWinnerIDs = []int{id}
+	g.awardPoint(id)
+	if j, err := g.getPlayerIndex(judgeID); err == nil {
+		g.Players[j].strikes = 0
+	}
+	g.next()
 	return nil
 }
 
-// VoteCard allows the game judge to pick their favorite card
-func (g *Game) VoteCard(judgeID int, cardID int) {
+// getSubmissionOwner returns who played a card this round
+func (g *Game) getSubmissionOwner(cardID int) (int, bool) {
+	for id, cards := range g.whitePlayed {
+		for _, c := range cards {
+			if c.ID == cardID {
+				return id, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// awardPoint gives a point to the winner of a round, phantom players are scored by the house rule that added them
+func (g *Game) awardPoint(winnerID int) {
+	if i, err := g.getPlayerIndex(winnerID); err == nil {
+		g.Players[i].score++
+	}
+	for _, r := range g.rules {
+		r.scored(g, winnerID)
+	}
 }
 
 // GetGenericState returns a simple generic state for a game
 func (g *Game) GetGenericState() GenericState {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	owner, _ := g.getPrivatePlayer(g.ownerID)
 	return GenericState{
 		Name:  g.Name,
@@ -202,17 +455,32 @@ func (g *Game) GetGenericState() GenericState {
 }
 
 func (g *Game) stop() {
+	g.reset()
+	g.stage = 0
+	g.updateUserStates()
+}
+
+// finish ends the game after a win condition is met and broadcasts the final standings
+func (g *Game) finish() {
+	g.reset()
+	g.stage = 4
+	g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/GAME_OVER", Payload: g.getStandings()})
+	g.updateUserStates()
+}
+
+// reset halts the stage timer and returns every card to the draw piles
+func (g *Game) reset() {
 	if g.isRunning() {
 		g.timer.Stop()
 	}
+	g.timerID++
 
-	for _, p := range g.Players {
-		g.whiteDraw = append(g.whiteDraw, p.hand...)
-		p.hand = []card.WhiteCard{}
+	for i := range g.Players {
+		g.whiteDraw = append(g.whiteDraw, g.Players[i].hand...)
+		g.Players[i].hand = []card.WhiteCard{}
 	}
 
 	g.judgeID = 0
-	g.stage = 0
 	g.nextStage = nil
 	g.timer = nil
 
@@ -222,64 +490,233 @@ func (g *Game) stop() {
 		g.whiteDraw = append(g.whiteDraw, list...)
 	}
 	g.whitePlayed = make(map[int][]card.WhiteCard)
+	g.playedOrder = nil
+	g.roundWinnerIDs = nil
+	g.votes = make(map[int]int)
+	g.eliminated = nil
+	g.eliminationOrder = nil
 
 	g.BlackDraw = append(g.BlackDraw, g.BlackDiscard...)
 	g.BlackDiscard = []card.BlackCard{}
-	if g.BlackCurrent != nil {
-		g.BlackDraw = append(g.BlackDraw, *g.BlackCurrent)
-		g.BlackCurrent = nil
-	}
-	g.updateUserStates()
+	g.putAwayBlackCard(&g.BlackDraw)
+	g.happyEnding = false
 }
 
+// next advances the game to its following stage
 func (g *Game) next() {
-	g.timer = time.AfterFunc(time.Duration(5)*time.Second, func() {
+	switch g.stage {
+	case 0:
+		g.beginRound(g.chooseJudge(g.nextJudgeID()))
+	case 3:
+		if g.winConditionMet() && !g.anyRule(func(r houseRule) bool { return r.extraRound(g) }) {
+			g.finish()
+		} else {
+			g.beginRound(g.chooseJudge(g.nextJudgeID()))
+		}
+	case 1:
+		g.returnIncompleteCards()
+		for _, r := range g.rules {
+			r.cardsPlayed(g)
+		}
+		if len(g.whitePlayed) == 0 {
+			// Nobody played anything, so there is nothing to judge
+			g.beginRound(g.chooseJudge(g.nextJudgeID()))
+		} else {
+			g.shufflePlayedOrder()
+			if !g.anyRule(func(r houseRule) bool { return r.judgingStarted(g) }) {
+				g.setStage(2, g.config.JudgeDuration)
+			}
+		}
+	case 2:
+		g.setStage(3, g.config.ScoreDuration)
+	}
+}
+
+// setStage moves the game into a stage and arms the timer that ends it
+func (g *Game) setStage(stage int, d time.Duration) {
+	if g.timer != nil {
+		g.timer.Stop()
+	}
+	g.stage = stage
+	nextStage := time.Now().Add(d)
+	g.nextStage = &nextStage
+	g.timerID++
+	timerID := g.timerID
+	g.timer = time.AfterFunc(d, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.timerID == timerID {
+			g.expire()
+		}
 	})
 	g.updateUserStates()
 }
 
+// beginRound starts a new round with the given judge, stopping the game if it cannot continue
+func (g *Game) beginRound(judgeID int) {
+	g.judgeID = judgeID
+	if !g.hasJudge() {
+		g.judgeID = 0
+	}
+	if err := g.startRound(); err != nil {
+		g.stop()
+		return
+	}
+	g.setStage(1, g.config.PlayDuration)
+}
+
+// voidRound abandons the current round and starts a new one, notifying players why
+func (g *Game) voidRound(judgeID int, reason string) {
+	if g.stage == 1 || g.stage == 2 {
+		for i, p := range g.Players {
+			// Spectators have no hand to return cards to, so theirs are discarded with the round
+			if !p.spectator {
+				g.Players[i].hand = append(g.Players[i].hand, g.whitePlayed[p.user.ID]...)
+				delete(g.whitePlayed, p.user.ID)
+			}
+		}
+		g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/ROUND_VOIDED", Payload: reason})
+	}
+	g.beginRound(judgeID)
+}
+
+// startRound clears the previous round, deals hands and draws a new black card
+func (g *Game) startRound() error {
+	for _, list := range g.whitePlayed {
+		g.whiteDiscard = append(g.whiteDiscard, list...)
+	}
+	g.whitePlayed = make(map[int][]card.WhiteCard)
+	g.playedOrder = nil
+	g.roundWinnerIDs = nil
+	g.votes = make(map[int]int)
+	g.eliminated = nil
+	g.eliminationOrder = nil
+	g.putAwayBlackCard(&g.BlackDiscard)
+
+	for _, r := range g.rules {
+		if bc := r.nextBlackCard(g); bc != nil {
+			g.BlackCurrent = bc
+		}
+	}
+	if g.BlackCurrent == nil {
+		bc, err := g.drawBlack()
+		if err != nil {
+			return err
+		}
+		g.BlackCurrent = &bc
+	}
+
+	g.round++
+	g.dealHands()
+	for _, r := range g.rules {
+		r.roundStarted(g)
+	}
+	return nil
+}
+
+// putAwayBlackCard moves the current black card onto a pile, unless a house rule is holding on to it
+func (g *Game) putAwayBlackCard(pile *[]card.BlackCard) {
+	if g.BlackCurrent == nil {
+		return
+	}
+	bc := *g.BlackCurrent
+	if !g.anyRule(func(r houseRule) bool { return r.keepsBlackCard(g, bc) }) {
+		*pile = append(*pile, bc)
+	}
+	g.BlackCurrent = nil
+}
+
+// returnIncompleteCards gives cards back to players who did not finish playing before the judge phase
+func (g *Game) returnIncompleteCards() {
+	for i, p := range g.Players {
+		if cards, ok := g.whitePlayed[p.user.ID]; ok && !g.userHasPlayed(p.user.ID) {
+			g.Players[i].hand = append(g.Players[i].hand, cards...)
+			delete(g.whitePlayed, p.user.ID)
+		}
+	}
+}
+
 ///////////////////////
 //// -- Helpers -- ////
 ///////////////////////
 
-func (g Game) getPrivatePlayer(pID int) (player, error) {
+func (g *Game) getPrivatePlayer(pID int) (player, error) {
 	for _, p := range g.Players {
 		if p.user.ID == pID {
 			return p, nil
 		}
 	}
-	return player{}, errors.New("User is not in this game")
+	return player{}, apperror.NotFound("User is not in this game")
 }
 
-func (g Game) getPublicPlayer(pID int) (Player, error) {
+func (g *Game) getPlayerIndex(pID int) (int, error) {
+	for i, p := range g.Players {
+		if p.user.ID == pID {
+			return i, nil
+		}
+	}
+	return -1, apperror.NotFound("User is not in this game")
+}
+
+func (g *Game) getPublicPlayer(pID int) (Player, error) {
 	pPriv, err := g.getPrivatePlayer(pID)
 	if err != nil {
 		return Player{}, err
 	}
-	return Player{User: pPriv.user, Score: pPriv.score, HasPlayed: g.userHasPlayed(pID)}, nil
+	return g.getPublicPlayerFromPrivate(pPriv), nil
 }
 
-func (g Game) getPublicPlayerFromPrivate(pPriv player) Player {
-	return Player{User: pPriv.user, Score: pPriv.score, HasPlayed: g.userHasPlayed(pPriv.user.ID)}
+func (g *Game) getPublicPlayerFromPrivate(pPriv player) Player {
+	return Player{
+		User:      pPriv.user,
+		Score:     pPriv.score,
+		HasPlayed: g.userHasPlayed(pPriv.user.ID),
+		Connected: pPriv.presence != PresenceDisconnected,
+		Presence:  pPriv.presence,
+		Spectator: pPriv.spectator,
+		Strikes:   pPriv.strikes,
+		Human:     true,
+	}
 }
 
 // userHasPlayed returns whether a user has played the correct number of cards for this round
-func (g Game) userHasPlayed(pID int) bool {
+func (g *Game) userHasPlayed(pID int) bool {
 	if g.BlackCurrent == nil {
 		return false
 	}
 	return g.BlackCurrent.AnswerFields == len(g.whitePlayed[pID])
 }
 
-func (g Game) getPublicPlayers() []Player {
+// allPlayersHavePlayed returns whether every player other than the judge has finished playing this round
+func (g *Game) allPlayersHavePlayed() bool {
+	for _, p := range g.Players {
+		if p.user.ID != g.judgeID && !p.spectator && !g.userHasPlayed(p.user.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *Game) getPublicPlayers() []Player {
 	pl := []Player{}
 	for _, p := range g.Players {
 		pl = append(pl, g.getPublicPlayerFromPrivate(p))
 	}
+	for _, r := range g.rules {
+		pl = append(pl, r.players(g)...)
+	}
 	return pl
 }
 
-func (g Game) playerIsInGame(pID int) bool {
+func (g *Game) getPlayerIDs() []int {
+	ids := []int{}
+	for _, p := range g.Players {
+		ids = append(ids, p.user.ID)
+	}
+	return ids
+}
+
+func (g *Game) playerIsInGame(pID int) bool {
 	for _, p := range g.Players {
 		if p.user.ID == pID {
 			return true
@@ -288,12 +725,128 @@ func (g Game) playerIsInGame(pID int) bool {
 	return false
 }
 
+// shufflePlayedOrder hides who played which submission by presenting them in a random order
+func (g *Game) shufflePlayedOrder() {
+	g.playedOrder = []int{}
+	for id := range g.whitePlayed {
+		g.playedOrder = append(g.playedOrder, id)
+	}
+	rand.Shuffle(len(g.playedOrder), func(i, j int) {
+		g.playedOrder[i], g.playedOrder[j] = g.playedOrder[j], g.playedOrder[i]
+	})
+}
+
+// winConditionMet returns whether any of the configured win conditions has been reached
+func (g *Game) winConditionMet() bool {
+	if g.anyRule(func(r houseRule) bool { return r.endsGame(g) }) {
+		return true
+	}
+	if g.settings.RoundLimit > 0 && g.round >= g.settings.RoundLimit {
+		return true
+	}
+	if g.settings.TimeLimit > 0 && time.Since(g.startedAt) >= time.Duration(g.settings.TimeLimit)*time.Minute {
+		return true
+	}
+	if g.settings.ScoreLimit > 0 {
+		for _, p := range g.getPublicPlayers() {
+			if p.Score >= g.settings.ScoreLimit {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// getStandings returns all players ordered from highest to lowest score
+func (g *Game) getStandings() []Player {
+	standings := g.getPublicPlayers()
+	sort.SliceStable(standings, func(i, j int) bool {
+		return standings[i].Score > standings[j].Score
+	})
+	return standings
+}
+
+// removeFromPlayedOrder removes a player's submission from the judging order
+func (g *Game) removeFromPlayedOrder(pID int) {
+	for i, id := range g.playedOrder {
+		if id == pID {
+			g.playedOrder = append(g.playedOrder[:i], g.playedOrder[i+1:]...)
+			return
+		}
+	}
+}
+
+// chooseJudge picks the judge for the next round according to the rotation policy,
+// falling back to sequentialID when the policy has no better candidate
+func (g *Game) chooseJudge(sequentialID int) int {
+	switch g.settings.JudgeRotation {
+	case RotationRandom:
+		active := []int{}
+		for _, p := range g.Players {
+			if !p.spectator {
+				active = append(active, p.user.ID)
+			}
+		}
+		if len(active) > 0 {
+			return active[rand.Intn(len(active))]
+		}
+	case RotationWinner:
+		winners := []int{}
+		for _, id := range g.roundWinnerIDs {
+			if i, err := g.getPlayerIndex(id); err == nil && !g.Players[i].spectator {
+				winners = append(winners, id)
+			}
+		}
+		if len(winners) > 0 {
+			return winners[rand.Intn(len(winners))]
+		}
+	}
+	return sequentialID
+}
+
+// roundWinnerID returns the first winner of this round, or 0 if nobody has won yet
+func (g *Game) roundWinnerID() int {
+	if len(g.roundWinnerIDs) == 0 {
+		return 0
+	}
+	return g.roundWinnerIDs[0]
+}
+
+// nextJudgeID returns the player after the current judge, wrapping around to the first player
+func (g *Game) nextJudgeID() int {
+	i, _ := g.getPlayerIndex(g.judgeID)
+	return g.nextActiveID(i)
+}
+
+// nextActiveID returns the first player after index i who is not spectating, wrapping around,
+// or the player straight after i if everyone is spectating
+func (g *Game) nextActiveID(i int) int {
+	n := len(g.Players)
+	for j := 1; j <= n; j++ {
+		if p := g.Players[(i+j)%n]; !p.spectator {
+			return p.user.ID
+		}
+	}
+	return g.Players[(i+1)%n].user.ID
+}
+
+// activePlayerCount returns the number of players who are not spectating
+func (g *Game) activePlayerCount() int {
+	count := 0
+	for _, p := range g.Players {
+		if !p.spectator {
+			count++
+		}
+	}
+	return count
+}
+
 func (g *Game) isRunning() bool {
 	return g.timer != nil
 }
 
 func (g *Game) updateUserStates() {
 	for _, u := range g.Players {
-		g.socketHandler.SendActionToUser(u.user.ID, socket.Action{Type: "game/SET_GAME_STATE", Payload: g.GetState(u.user.ID)})
+		g.socketHandler.SendActionToUser(u.user.ID, socket.Action{Type: socket.StateAction, Payload: g.getState(u.user.ID)})
 	}
 }
@@ -0,0 +1,562 @@
+package game
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googollee/go-socket.io"
+
+	"../../apperror"
+	"../../card"
+	"../../server/socket"
+	"../../user"
+)
+
+// createTestGame creates a game with 200 white cards and the given black cards, or 20 black cards mixing pick 1, 2 and 3
+func createTestGame(t *testing.T, settings Settings, blackCards ...card.BlackCard) *Game {
+	wc := []card.WhiteCard{}
+	for i := 1; i <= 200; i++ {
+		wc = append(wc, card.CreateWhiteCard(i, "White", 1))
+	}
+	if len(blackCards) == 0 {
+		for i := 1; i <= 20; i++ {
+			blackCards = append(blackCards, card.CreateBlackCard(i, "Black", i%3+1, 1))
+		}
+	}
+	g, err := CreateGame("Test", 10, settings, DefaultConfig(), wc, blackCards, socket.CreateHandler())
+	if err != nil {
+		t.Fatalf("Failed: Could not create game - %v", err)
+	}
+	return g
+}
+
+// pickCards returns 20 black cards that all need the given number of answers
+func pickCards(pick int) []card.BlackCard {
+	bc := []card.BlackCard{}
+	for i := 1; i <= 20; i++ {
+		bc = append(bc, card.CreateBlackCard(i, "Black", pick, 1))
+	}
+	return bc
+}
+
+func checkInvariants(t *testing.T, g *Game, step string) {
+	if err := g.CheckInvariants(); err != nil {
+		t.Fatalf("Failed: Invariant broken after %s - %v", step, err)
+	}
+}
+
+func TestStopReturnsHands(t *testing.T) {
+	g := createTestGame(t, Settings{})
+	for i := 1; i <= 4; i++ {
+		g.Join(user.User{ID: i})
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	if len(g.Players[0].hand) == 0 {
+		t.Errorf("Failed: Expected hands to be dealt on start")
+	}
+	if err := g.Stop(1); err != nil {
+		t.Fatalf("Failed: Could not stop game - %v", err)
+	}
+	for _, p := range g.Players {
+		if len(p.hand) != 0 {
+			t.Errorf("Failed: Expected player %d to have an empty hand after stopping, has %d cards", p.user.ID, len(p.hand))
+		}
+	}
+	checkInvariants(t, g, "stop")
+	if len(g.whiteDraw) != 200 {
+		t.Errorf("Failed: Expected all 200 white cards in the draw pile, found %d", len(g.whiteDraw))
+	}
+}
+
+func TestCardConservation(t *testing.T) {
+	for seed := int64(0); seed < 50; seed++ {
+		r := rand.New(rand.NewSource(seed))
+		settings := Settings{}
+		switch seed % 5 {
+		case 1:
+			settings.HouseRules = []string{RuleRando, RulePackingHeat, RuleReboot}
+		case 2:
+			settings.HouseRules = []string{RuleRando, RulePackingHeat, RuleReboot, RuleGodIsDead}
+		case 3:
+			settings.HouseRules = []string{RuleRando, RulePackingHeat, RuleReboot, RuleSurvival}
+		case 4:
+			settings.HouseRules = []string{RuleHappyEnding, RuleNeverHaveI}
+		}
+		g := createTestGame(t, settings)
+		g.config.AFKStrikeLimit = 2
+		for step := 0; step < 200; step++ {
+			uID := r.Intn(8) + 1
+			switch r.Intn(10) {
+			case 0:
+				g.Join(user.User{ID: uID})
+			case 1:
+				g.Leave(uID)
+			case 2:
+				g.Start(g.ownerID)
+			case 3:
+				g.Stop(g.ownerID)
+			case 4:
+				if p, err := g.getPrivatePlayer(uID); err == nil && len(p.hand) > 0 {
+					g.PlayCard(uID, p.hand[r.Intn(len(p.hand))].ID)
+				}
+			case 5:
+				voterID := g.judgeID
+				if voterID == 0 {
+					voterID = uID
+				}
+				for _, cards := range g.whitePlayed {
+					g.VoteCard(voterID, cards[0].ID)
+					break
+				}
+			case 6:
+				if g.isRunning() {
+					g.expire()
+				}
+			case 7:
+				g.Resume(uID)
+			case 8:
+				if i, err := g.getPlayerIndex(uID); err == nil {
+					g.Players[i].score++
+					g.RebootHand(uID)
+				}
+			case 9:
+				if p, err := g.getPrivatePlayer(uID); err == nil && len(p.hand) > 0 {
+					g.DiscardCard(uID, p.hand[r.Intn(len(p.hand))].ID)
+				}
+				g.DeclareHappyEnding(uID)
+			}
+			checkInvariants(t, g, "a random action")
+		}
+		g.reset()
+		checkInvariants(t, g, "reset")
+	}
+}
+
+// recordingSocket collects the actions emitted to it
+type recordingSocket struct {
+	mu      sync.Mutex
+	actions []socket.Action
+}
+
+func (s *recordingSocket) Id() string                                      { return "recording" }
+func (s *recordingSocket) Rooms() []string                                 { return nil }
+func (s *recordingSocket) Request() *http.Request                          { return nil }
+func (s *recordingSocket) On(event string, f interface{}) error            { return nil }
+func (s *recordingSocket) Join(room string) error                          { return nil }
+func (s *recordingSocket) Leave(room string) error                         { return nil }
+func (s *recordingSocket) Disconnect()                                     {}
+func (s *recordingSocket) BroadcastTo(r, e string, a ...interface{}) error { return nil }
+func (s *recordingSocket) Emit(event string, args ...interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions = append(s.actions, args[0].(socket.Action))
+	return nil
+}
+
+// waitForAction waits for an action of the given type that passes the check, failing the test after a second
+func (s *recordingSocket) waitForAction(t *testing.T, actionType string, check func(socket.Action) bool) {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		for _, a := range s.actions {
+			if a.Type == actionType && check(a) {
+				s.mu.Unlock()
+				return
+			}
+		}
+		s.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Failed: Expected a %s action", actionType)
+}
+
+// recordActions registers a recording socket for a user of the game
+func recordActions(g *Game, uID int) *recordingSocket {
+	s := &recordingSocket{}
+	var soc socketio.Socket = s
+	g.socketHandler.Add(uID, &soc)
+	return s
+}
+
+func TestRoundStateMachine(t *testing.T) {
+	g := createTestGame(t, Settings{})
+	for i := 1; i <= 4; i++ {
+		g.Join(user.User{ID: i})
+	}
+	s := recordActions(g, 1)
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	defer g.Halt()
+	if g.stage != 1 || g.round != 1 || g.BlackCurrent == nil || g.judgeID == 0 || g.nextStage == nil {
+		t.Fatalf("Failed: Expected the first round's card play phase, stage %d round %d", g.stage, g.round)
+	}
+	first := *g.BlackCurrent
+
+	playFor(g)
+	if g.stage != 2 || len(g.playedOrder) != 3 {
+		t.Fatalf("Failed: Expected the judge phase with 3 submissions, stage %d", g.stage)
+	}
+	s.waitForAction(t, "game/SET_GAME_STATE", func(a socket.Action) bool {
+		return a.Payload.(UserState).CurrentStage == 2
+	})
+
+	if err := g.VoteCard(g.judgeID, g.whitePlayed[g.playedOrder[0]][0].ID); err != nil {
+		t.Fatalf("Failed: Could not vote - %v", err)
+	}
+	if g.stage != 3 {
+		t.Fatalf("Failed: Expected the scoring phase after judging, stage %d", g.stage)
+	}
+	g.next()
+	if g.stage != 1 || g.round != 2 || len(g.whitePlayed) != 0 || g.BlackCurrent.ID == first.ID {
+		t.Errorf("Failed: Expected a fresh round with a new black card, stage %d round %d", g.stage, g.round)
+	}
+	if len(g.BlackDiscard) != 1 || g.BlackDiscard[0].ID != first.ID {
+		t.Errorf("Failed: Expected the last black card to be discarded")
+	}
+	checkInvariants(t, g, "a full round")
+}
+
+func TestStageTimerAdvancesRound(t *testing.T) {
+	g := createTestGame(t, Settings{})
+	g.config.PlayDuration = 10 * time.Millisecond
+	g.config.JudgeDuration = time.Hour
+	for i := 1; i <= 4; i++ {
+		g.Join(user.User{ID: i})
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	defer g.Halt()
+	deadline := time.Now().Add(time.Second)
+	for g.GetState(1).CurrentStage != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Failed: Expected the play timer to move the game to judging")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPlayCard(t *testing.T) {
+	g := createTestGame(t, Settings{}, pickCards(2)...)
+	for i := 1; i <= 4; i++ {
+		g.Join(user.User{ID: i})
+	}
+	if err := g.PlayCard(2, 1); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected playing before the game starts to be rejected, got %v", err)
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	defer g.Halt()
+
+	judge, _ := g.getPrivatePlayer(g.judgeID)
+	if err := g.PlayCard(g.judgeID, judge.hand[0].ID); !errors.Is(err, apperror.ErrForbidden) {
+		t.Errorf("Failed: Expected the judge to be stopped from playing, got %v", err)
+	}
+	pID := g.nextJudgeID()
+	p, _ := g.getPrivatePlayer(pID)
+	if err := g.PlayCard(pID, judge.hand[0].ID); !errors.Is(err, apperror.ErrValidation) {
+		t.Errorf("Failed: Expected a card from someone else's hand to be rejected, got %v", err)
+	}
+	hand := append([]card.WhiteCard{}, p.hand...)
+	for _, c := range hand[:2] {
+		if err := g.PlayCard(pID, c.ID); err != nil {
+			t.Fatalf("Failed: Could not play card - %v", err)
+		}
+	}
+	if err := g.PlayCard(pID, hand[2].ID); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected a third card for a pick-2 black card to be rejected, got %v", err)
+	}
+	played := g.whitePlayed[pID]
+	if len(played) != 2 || played[0].ID != hand[0].ID || played[1].ID != hand[1].ID {
+		t.Errorf("Failed: Expected both cards to be played in order, got %v", played)
+	}
+	if p, _ := g.getPrivatePlayer(pID); len(p.hand) != g.settings.HandSize-2 {
+		t.Errorf("Failed: Expected the played cards to leave the hand, has %d cards", len(p.hand))
+	}
+	if g.stage != 1 {
+		t.Errorf("Failed: Expected card play to continue until everyone has played")
+	}
+	playFor(g)
+	if g.stage != 2 {
+		t.Errorf("Failed: Expected judging to start early once everyone has played, stage %d", g.stage)
+	}
+	checkInvariants(t, g, "playing cards")
+}
+
+func TestVoteCard(t *testing.T) {
+	g := startTestGame(t, Settings{}, 4)
+	defer g.Halt()
+	if err := g.VoteCard(g.judgeID, 1); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected voting during card play to be rejected, got %v", err)
+	}
+	playFor(g)
+	winnerID := g.playedOrder[0]
+	winning := g.whitePlayed[winnerID]
+	if err := g.VoteCard(winnerID, winning[0].ID); !errors.Is(err, apperror.ErrForbidden) {
+		t.Errorf("Failed: Expected a player other than the judge to be stopped from voting, got %v", err)
+	}
+	judge, _ := g.getPrivatePlayer(g.judgeID)
+	if err := g.VoteCard(g.judgeID, judge.hand[0].ID); !errors.Is(err, apperror.ErrValidation) {
+		t.Errorf("Failed: Expected a card that was not played to be rejected, got %v", err)
+	}
+	if err := g.VoteCard(g.judgeID, winning[len(winning)-1].ID); err != nil {
+		t.Fatalf("Failed: Could not vote - %v", err)
+	}
+	if g.stage != 3 || totalScore(g) != 1 {
+		t.Fatalf("Failed: Expected one point to be awarded before scoring, stage %d", g.stage)
+	}
+	if p, _ := g.getPrivatePlayer(winnerID); p.score != 1 {
+		t.Errorf("Failed: Expected player %d to win the point, has %d", winnerID, p.score)
+	}
+	state := g.GetState(g.judgeID)
+	if !reflect.DeepEqual(state.RoundWinnerIDs, []int{winnerID}) || !reflect.DeepEqual(state.WinningCards, winning) {
+		t.Errorf("Failed: Expected the winning submission in the state, got %v %v", state.RoundWinnerIDs, state.WinningCards)
+	}
+}
+
+// judgeRound finishes the current round with the judge picking the first submission
+func judgeRound(t *testing.T, g *Game) {
+	playFor(g)
+	if err := g.VoteCard(g.judgeID, g.whitePlayed[g.playedOrder[0]][0].ID); err != nil {
+		t.Fatalf("Failed: Could not vote - %v", err)
+	}
+	g.next()
+}
+
+func TestJudgeRotation(t *testing.T) {
+	g := startTestGame(t, Settings{}, 4)
+	for round := 0; round < 6; round++ {
+		if expected := g.Players[round%4].user.ID; g.judgeID != expected {
+			t.Errorf("Failed: Expected player %d to judge round %d, got %d", expected, round+1, g.judgeID)
+		}
+		judgeRound(t, g)
+	}
+	g.Halt()
+
+	g = startTestGame(t, Settings{JudgeRotation: RotationWinner}, 4)
+	for round := 0; round < 3; round++ {
+		playFor(g)
+		winnerID := g.playedOrder[0]
+		g.VoteCard(g.judgeID, g.whitePlayed[winnerID][0].ID)
+		g.next()
+		if g.judgeID != winnerID {
+			t.Errorf("Failed: Expected round winner %d to judge next, got %d", winnerID, g.judgeID)
+		}
+	}
+	g.Halt()
+
+	g = startTestGame(t, Settings{JudgeRotation: RotationRandom}, 4)
+	defer g.Halt()
+	for round := 0; round < 6; round++ {
+		if !g.playerIsInGame(g.judgeID) {
+			t.Errorf("Failed: Expected a random player to judge, got %d", g.judgeID)
+		}
+		judgeRound(t, g)
+	}
+}
+
+func TestJudgeLeavingVoidsRound(t *testing.T) {
+	g := startTestGame(t, Settings{}, 5)
+	defer g.Halt()
+	s := recordActions(g, 3)
+	playFor(g)
+	judgeID := g.judgeID
+	successorID := g.nextJudgeID()
+	g.Leave(judgeID)
+
+	if g.stage != 1 || g.round != 2 || len(g.whitePlayed) != 0 {
+		t.Fatalf("Failed: Expected the round to restart, stage %d round %d", g.stage, g.round)
+	}
+	if g.judgeID != successorID {
+		t.Errorf("Failed: Expected player %d to take over judging, got %d", successorID, g.judgeID)
+	}
+	for _, p := range g.Players {
+		if len(p.hand) != g.settings.HandSize {
+			t.Errorf("Failed: Expected player %d to get their played cards back, has %d cards", p.user.ID, len(p.hand))
+		}
+	}
+	s.waitForAction(t, "game/ROUND_VOIDED", func(socket.Action) bool { return true })
+	checkInvariants(t, g, "the judge leaving")
+}
+
+func TestLeavingStopsBelowMinPlayers(t *testing.T) {
+	g := startTestGame(t, Settings{}, 4)
+	defer g.Halt()
+	g.Leave(g.nextJudgeID())
+	if !g.isRunning() {
+		t.Errorf("Failed: Expected the game to carry on with %d players", g.config.MinPlayers)
+	}
+	g.Leave(g.nextJudgeID())
+	if g.isRunning() || g.stage != 0 {
+		t.Errorf("Failed: Expected the game to stop below %d players", g.config.MinPlayers)
+	}
+
+	g = createTestGame(t, Settings{})
+	g.config.MinPlayers = 5
+	for i := 1; i <= 5; i++ {
+		g.Join(user.User{ID: i})
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	g.Leave(g.nextJudgeID())
+	if g.isRunning() {
+		t.Errorf("Failed: Expected the configured minimum of 5 players to stop the game")
+	}
+	checkInvariants(t, g, "leaving")
+}
+
+func TestWinConditions(t *testing.T) {
+	if _, err := CreateGame("Test", 10, Settings{ScoreLimit: -1}, DefaultConfig(), nil, nil, socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a negative win condition to be rejected")
+	}
+
+	g := startTestGame(t, Settings{RoundLimit: 3}, 4)
+	for round := 1; round <= 3; round++ {
+		if g.stage == 4 {
+			t.Fatalf("Failed: Expected the game to last 3 rounds, ended after %d", round-1)
+		}
+		judgeRound(t, g)
+	}
+	if g.stage != 4 || g.isRunning() {
+		t.Errorf("Failed: Expected the round limit to end the game, stage %d", g.stage)
+	}
+	checkInvariants(t, g, "round limit")
+
+	g = startTestGame(t, Settings{ScoreLimit: 2}, 4)
+	for g.stage != 4 {
+		for _, p := range g.Players {
+			if p.score >= 2 {
+				t.Fatalf("Failed: Expected the game to end when player %d reached the score limit", p.user.ID)
+			}
+		}
+		judgeRound(t, g)
+	}
+	if standings := g.getStandings(); standings[0].Score != 2 {
+		t.Errorf("Failed: Expected the winner to have 2 points, got %d", standings[0].Score)
+	}
+
+	g = startTestGame(t, Settings{TimeLimit: 5}, 4)
+	judgeRound(t, g)
+	if g.stage != 1 {
+		t.Fatalf("Failed: Expected the game to continue within the time limit")
+	}
+	g.startedAt = time.Now().Add(-5 * time.Minute)
+	judgeRound(t, g)
+	if g.stage != 4 {
+		t.Errorf("Failed: Expected the time limit to end the game, stage %d", g.stage)
+	}
+}
+
+func TestGameOverAndRematch(t *testing.T) {
+	g := createTestGame(t, Settings{ScoreLimit: 1})
+	for i := 1; i <= 4; i++ {
+		g.Join(user.User{ID: i})
+	}
+	s := recordActions(g, 2)
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	playFor(g)
+	winnerID := g.playedOrder[0]
+	g.VoteCard(g.judgeID, g.whitePlayed[winnerID][0].ID)
+	g.next()
+	if g.stage != 4 {
+		t.Fatalf("Failed: Expected the game to be over, stage %d", g.stage)
+	}
+	s.waitForAction(t, "game/GAME_OVER", func(a socket.Action) bool {
+		standings := a.Payload.([]Player)
+		return len(standings) == 4 && standings[0].User.ID == winnerID && standings[0].Score == 1
+	})
+
+	if err := g.Start(2); err == nil {
+		t.Errorf("Failed: Expected only the owner to start a rematch")
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start a rematch - %v", err)
+	}
+	defer g.Halt()
+	if g.stage != 1 || g.round != 1 || len(g.Players) != 4 || totalScore(g) != 0 {
+		t.Errorf("Failed: Expected a rematch with the same players and no points, stage %d", g.stage)
+	}
+	checkInvariants(t, g, "rematch")
+}
+
+func TestHandsAreDealtAndRefilled(t *testing.T) {
+	if _, err := CreateGame("Test", 10, Settings{HandSize: 2}, DefaultConfig(), nil, nil, socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a hand too small for pick-3 cards to be rejected")
+	}
+	g := startTestGame(t, Settings{}, 4, pickCards(2)...)
+	defer g.Halt()
+	if g.settings.HandSize != 10 {
+		t.Errorf("Failed: Expected hands of 10 cards by default, got %d", g.settings.HandSize)
+	}
+	for _, p := range g.Players {
+		if len(p.hand) != 10 {
+			t.Errorf("Failed: Expected player %d to be dealt 10 cards, has %d", p.user.ID, len(p.hand))
+		}
+	}
+	judgeRound(t, g)
+	for _, p := range g.Players {
+		if len(p.hand) != 10 {
+			t.Errorf("Failed: Expected player %d to be topped up after playing 2 cards, has %d", p.user.ID, len(p.hand))
+		}
+	}
+	if len(g.whiteDraw) != 200-40-6 || len(g.whiteDiscard) != 6 {
+		t.Errorf("Failed: Expected the played cards to be discarded and replaced from the draw pile")
+	}
+
+	g = startTestGame(t, Settings{HandSize: 5}, 4)
+	for _, p := range g.Players {
+		if len(p.hand) != 5 {
+			t.Errorf("Failed: Expected player %d to be dealt the configured 5 cards, has %d", p.user.ID, len(p.hand))
+		}
+	}
+	g.Halt()
+}
+
+func TestDrawPilesReshuffleDiscards(t *testing.T) {
+	g := createTestGame(t, Settings{})
+	g.whiteDiscard, g.whiteDraw = g.whiteDraw, nil
+	if _, err := g.drawWhite(); err != nil || len(g.whiteDraw) != 199 || len(g.whiteDiscard) != 0 {
+		t.Errorf("Failed: Expected the white discards to become the draw pile, drew %d", len(g.whiteDraw))
+	}
+	g.BlackDiscard, g.BlackDraw = g.BlackDraw, nil
+	if _, err := g.drawBlack(); err != nil || len(g.BlackDraw) != 19 || len(g.BlackDiscard) != 0 {
+		t.Errorf("Failed: Expected the black discards to become the draw pile, drew %d", len(g.BlackDraw))
+	}
+	g.whiteDraw, g.BlackDraw = nil, nil
+	if _, err := g.drawWhite(); err == nil {
+		t.Errorf("Failed: Expected an error when no white cards remain")
+	}
+	if _, err := g.drawBlack(); err == nil {
+		t.Errorf("Failed: Expected an error when no black cards remain")
+	}
+}
+
+func TestJoiningRunningGameDealsHand(t *testing.T) {
+	g := startTestGame(t, Settings{}, 4)
+	defer g.Halt()
+	g.Join(user.User{ID: 5})
+	if p, _ := g.getPrivatePlayer(5); len(p.hand) != g.settings.HandSize {
+		t.Fatalf("Failed: Expected a player joining mid-round to be dealt in, has %d cards", len(p.hand))
+	}
+	checkInvariants(t, g, "joining")
+	playFor(g, 5)
+	if g.stage != 1 {
+		t.Fatalf("Failed: Expected card play to wait for the new player")
+	}
+	playFor(g)
+	if g.stage != 2 || len(g.playedOrder) != 4 {
+		t.Errorf("Failed: Expected the new player's submission to be judged, stage %d", g.stage)
+	}
+}
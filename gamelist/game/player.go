@@ -5,15 +5,30 @@ import (
 	"../../user"
 )
 
+// Player presence
+const (
+	PresenceConnected    = "connected"
+	PresenceAway         = "away"
+	PresenceDisconnected = "disconnected"
+)
+
 // Player a user that also contains other game-specific player data
 type Player struct {
 	User      user.User `json:"user"`
 	Score     int       `json:"score"`
 	HasPlayed bool      `json:"hasPlayed"`
+	Connected bool      `json:"connected"`
+	Presence  string    `json:"presence"`
+	Spectator bool      `json:"spectator"`
+	Strikes   int       `json:"strikes"` // Deadlines missed in a row
+	Human     bool      `json:"human"`   // False for phantom players added by house rules
 }
 
 type player struct {
-	user  user.User
-	hand  []card.WhiteCard
-	score int
+	user      user.User
+	hand      []card.WhiteCard
+	score     int
+	presence  string
+	spectator bool
+	strikes   int
 }
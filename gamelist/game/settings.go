@@ -0,0 +1,130 @@
+package game
+
+import (
+	"fmt"
+	"time"
+
+	"../../apperror"
+)
+
+// Config - Server wide limits and defaults that apply to every game
+type Config struct {
+	PlayDuration           time.Duration
+	JudgeDuration          time.Duration
+	ScoreDuration          time.Duration
+	EliminationDuration    time.Duration // Time for each turn when eliminating submissions
+	DefaultHandSize        int
+	MinPlayers             int
+	MaxPlayers             int
+	MinBlackCards          int
+	MinWhiteCardsPerPlayer int
+	ReconnectGrace         time.Duration // How long a disconnected player keeps their seat
+	AFKStrikeLimit         int           // Deadlines a player may miss in a row before spectating, 0 for no limit
+}
+
+// DefaultConfig returns the limits used when nothing else is configured
+func DefaultConfig() Config {
+	return Config{
+		PlayDuration:           60 * time.Second,
+		JudgeDuration:          30 * time.Second,
+		ScoreDuration:          10 * time.Second,
+		EliminationDuration:    15 * time.Second,
+		DefaultHandSize:        10,
+		MinPlayers:             3,
+		MaxPlayers:             20,
+		MinBlackCards:          10,
+		MinWhiteCardsPerPlayer: 10,
+		ReconnectGrace:         60 * time.Second,
+		AFKStrikeLimit:         3,
+	}
+}
+
+// Hand size limits, hands must hold enough cards to answer a pick-3 black card
+const (
+	MinHandSize = 3
+	MaxHandSize = 20
+)
+
+// Judge rotation policies
+const (
+	RotationSequential = "sequential"
+	RotationRandom     = "random"
+	RotationWinner     = "winner"
+)
+
+// What happens to players who miss the card play deadline
+const (
+	AFKAutoPlay = "autoplay"
+	AFKSkip     = "skip"
+)
+
+// What happens when the judge misses the judging deadline
+const (
+	IdleJudgeReplace = "replace"
+	IdleJudgeVoid    = "void"
+)
+
+// How tied votes are settled in God Is Dead games
+const (
+	TieRandom = "random" // One of the tied submissions wins at random
+	TieShare  = "share"  // Every tied submission earns a point
+	TieNone   = "none"   // Nobody scores
+)
+
+// Settings - Options chosen by the owner when creating a game
+type Settings struct {
+	JudgeRotation   string   `json:"judgeRotation"`
+	ScoreLimit      int      `json:"scoreLimit"` // Points needed to win, 0 for no limit
+	RoundLimit      int      `json:"roundLimit"` // Rounds to play, 0 for no limit
+	TimeLimit       int      `json:"timeLimit"`  // Minutes to play, 0 for no limit
+	HandSize        int      `json:"handSize"`
+	AFKPolicy       string   `json:"afkPolicy"`
+	IdleJudgePolicy string   `json:"idleJudgePolicy"`
+	HouseRules      []string `json:"houseRules"`
+	TiePolicy       string   `json:"tiePolicy"`
+}
+
+// validate fills in defaults and checks that all settings are usable
+func (s *Settings) validate(config Config) error {
+	switch s.JudgeRotation {
+	case "":
+		s.JudgeRotation = RotationSequential
+	case RotationSequential, RotationRandom, RotationWinner:
+	default:
+		return apperror.Validation("Unknown judge rotation policy")
+	}
+	switch s.AFKPolicy {
+	case "":
+		s.AFKPolicy = AFKAutoPlay
+	case AFKAutoPlay, AFKSkip:
+	default:
+		return apperror.Validation("Unknown AFK policy")
+	}
+	switch s.IdleJudgePolicy {
+	case "":
+		s.IdleJudgePolicy = IdleJudgeReplace
+	case IdleJudgeReplace, IdleJudgeVoid:
+	default:
+		return apperror.Validation("Unknown idle judge policy")
+	}
+	switch s.TiePolicy {
+	case "":
+		s.TiePolicy = TieRandom
+	case TieRandom, TieShare, TieNone:
+	default:
+		return apperror.Validation("Unknown tie policy")
+	}
+	if err := validateHouseRules(s.HouseRules); err != nil {
+		return err
+	}
+	if s.ScoreLimit < 0 || s.RoundLimit < 0 || s.TimeLimit < 0 {
+		return apperror.Validation("Win conditions must not be negative")
+	}
+	if s.HandSize == 0 {
+		s.HandSize = config.DefaultHandSize
+	}
+	if s.HandSize < MinHandSize || s.HandSize > MaxHandSize {
+		return apperror.Validation(fmt.Sprintf("Hand size must be between %d and %d", MinHandSize, MaxHandSize))
+	}
+	return nil
+}
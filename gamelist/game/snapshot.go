@@ -0,0 +1,206 @@
+package game
+
+import (
+	"sort"
+	"time"
+
+	"../../apperror"
+	"../../card"
+	"../../server/socket"
+	"../../user"
+)
+
+// Snapshot - Everything needed to recreate a game after the server restarts, including private hands
+type Snapshot struct {
+	Name             string                   `json:"name"`
+	MaxPlayers       int                      `json:"maxPlayers"`
+	Settings         Settings                 `json:"settings"`
+	Players          []PlayerSnapshot         `json:"players"`
+	OwnerID          int                      `json:"ownerId"`
+	JudgeID          int                      `json:"judgeId"`
+	Stage            int                      `json:"stage"`
+	Round            int                      `json:"round"`
+	StartedAt        time.Time                `json:"startedAt"`
+	Remaining        time.Duration            `json:"remaining"` // Time left in the current stage when the snapshot was taken
+	WhiteDraw        []card.WhiteCard         `json:"whiteDraw"`
+	WhiteDiscard     []card.WhiteCard         `json:"whiteDiscard"`
+	WhitePlayed      map[int][]card.WhiteCard `json:"whitePlayed"`
+	PlayedOrder      []int                    `json:"playedOrder"`
+	RoundWinnerIDs   []int                    `json:"roundWinnerIds"`
+	BlackDraw        []card.BlackCard         `json:"blackDraw"`
+	BlackDiscard     []card.BlackCard         `json:"blackDiscard"`
+	BlackCurrent     *card.BlackCard          `json:"blackCurrent"`
+	PhantomScores    map[int]int              `json:"phantomScores"`
+	Votes            map[int]int              `json:"votes"`
+	Eliminated       []int                    `json:"eliminated"`
+	EliminationOrder []int                    `json:"eliminationOrder"`
+	EliminationTurn  int                      `json:"eliminationTurn"`
+	HaikuCard        *card.BlackCard          `json:"haikuCard"`
+	HappyEnding      bool                     `json:"happyEnding"`
+	WhiteIDs         []int                    `json:"whiteIds"` // Every white card the game was created with
+	BlackIDs         []int                    `json:"blackIds"` // Every black card the game was created with
+}
+
+// PlayerSnapshot - A player's private state within a snapshot
+type PlayerSnapshot struct {
+	User      user.User        `json:"user"`
+	Hand      []card.WhiteCard `json:"hand"`
+	Score     int              `json:"score"`
+	Spectator bool             `json:"spectator"`
+	Strikes   int              `json:"strikes"`
+}
+
+// Snapshot captures the full state of the game
+func (g *Game) Snapshot() Snapshot {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s := Snapshot{
+		Name:             g.Name,
+		MaxPlayers:       g.MaxPlayers,
+		Settings:         g.settings,
+		Players:          []PlayerSnapshot{},
+		OwnerID:          g.ownerID,
+		JudgeID:          g.judgeID,
+		Stage:            g.stage,
+		Round:            g.round,
+		StartedAt:        g.startedAt,
+		WhiteDraw:        append([]card.WhiteCard{}, g.whiteDraw...),
+		WhiteDiscard:     append([]card.WhiteCard{}, g.whiteDiscard...),
+		WhitePlayed:      make(map[int][]card.WhiteCard),
+		PlayedOrder:      append([]int{}, g.playedOrder...),
+		RoundWinnerIDs:   append([]int(nil), g.roundWinnerIDs...),
+		BlackDraw:        append([]card.BlackCard{}, g.BlackDraw...),
+		BlackDiscard:     append([]card.BlackCard{}, g.BlackDiscard...),
+		PhantomScores:    make(map[int]int),
+		Votes:            make(map[int]int),
+		Eliminated:       append([]int{}, g.eliminated...),
+		EliminationOrder: append([]int{}, g.eliminationOrder...),
+		EliminationTurn:  g.eliminationTurn,
+		HappyEnding:      g.happyEnding,
+		WhiteIDs:         sortedIDs(g.whiteIDs),
+		BlackIDs:         sortedIDs(g.blackIDs),
+	}
+	for _, p := range g.Players {
+		s.Players = append(s.Players, PlayerSnapshot{
+			User:      p.user,
+			Hand:      append([]card.WhiteCard{}, p.hand...),
+			Score:     p.score,
+			Spectator: p.spectator,
+			Strikes:   p.strikes,
+		})
+	}
+	for id, cards := range g.whitePlayed {
+		s.WhitePlayed[id] = append([]card.WhiteCard{}, cards...)
+	}
+	if g.BlackCurrent != nil {
+		bc := *g.BlackCurrent
+		s.BlackCurrent = &bc
+	}
+	if g.haikuCard != nil {
+		haiku := *g.haikuCard
+		s.HaikuCard = &haiku
+	}
+	for id, score := range g.phantomScores {
+		s.PhantomScores[id] = score
+	}
+	for voterID, id := range g.votes {
+		s.Votes[voterID] = id
+	}
+	if g.nextStage != nil {
+		if s.Remaining = time.Until(*g.nextStage); s.Remaining < 0 {
+			s.Remaining = 0
+		}
+	}
+	return s
+}
+
+// RestoreGame recreates a game from a snapshot, re-arming the stage timer with the time that was left.
+// Every player starts out disconnected until their socket reconnects.
+func RestoreGame(s Snapshot, config Config, socketHandler *socket.Handler) (*Game, error) {
+	if s.Stage < 0 || s.Stage > 4 {
+		return &Game{}, apperror.Validation("Snapshot has an unknown stage")
+	}
+	if s.Stage >= 1 && s.Stage <= 3 && s.BlackCurrent == nil {
+		return &Game{}, apperror.Validation("Snapshot of a running game has no black card")
+	}
+	if len(s.WhiteIDs) == 0 || len(s.BlackIDs) == 0 {
+		return &Game{}, apperror.Validation("Snapshot does not list the game's cards")
+	}
+	if err := validateHouseRules(s.Settings.HouseRules); err != nil {
+		return &Game{}, err
+	}
+	g := Game{
+		Name:             s.Name,
+		MaxPlayers:       s.MaxPlayers,
+		config:           config,
+		settings:         s.Settings,
+		ownerID:          s.OwnerID,
+		judgeID:          s.JudgeID,
+		stage:            s.Stage,
+		round:            s.Round,
+		startedAt:        s.StartedAt,
+		socketHandler:    socketHandler,
+		whiteDraw:        s.WhiteDraw,
+		whiteDiscard:     s.WhiteDiscard,
+		whitePlayed:      s.WhitePlayed,
+		playedOrder:      s.PlayedOrder,
+		roundWinnerIDs:   s.RoundWinnerIDs,
+		BlackDraw:        s.BlackDraw,
+		BlackDiscard:     s.BlackDiscard,
+		BlackCurrent:     s.BlackCurrent,
+		whiteIDs:         make(map[int]bool),
+		blackIDs:         make(map[int]bool),
+		rules:            getHouseRules(s.Settings.HouseRules),
+		phantomScores:    s.PhantomScores,
+		votes:            s.Votes,
+		eliminated:       s.Eliminated,
+		eliminationOrder: s.EliminationOrder,
+		eliminationTurn:  s.EliminationTurn,
+		haikuCard:        s.HaikuCard,
+		happyEnding:      s.HappyEnding,
+	}
+	if g.whitePlayed == nil {
+		g.whitePlayed = make(map[int][]card.WhiteCard)
+	}
+	if g.phantomScores == nil {
+		g.phantomScores = make(map[int]int)
+	}
+	if g.votes == nil {
+		g.votes = make(map[int]int)
+	}
+	for _, p := range s.Players {
+		g.Players = append(g.Players, player{
+			user:      p.User,
+			hand:      p.Hand,
+			score:     p.Score,
+			presence:  PresenceDisconnected,
+			spectator: p.Spectator,
+			strikes:   p.Strikes,
+		})
+	}
+	for _, id := range s.WhiteIDs {
+		g.whiteIDs[id] = true
+	}
+	for _, id := range s.BlackIDs {
+		g.blackIDs[id] = true
+	}
+	if err := g.CheckInvariants(); err != nil {
+		return &Game{}, apperror.Validation("Snapshot is inconsistent - " + err.Error())
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.stage >= 1 && g.stage <= 3 {
+		g.setStage(g.stage, s.Remaining)
+	}
+	return &g, nil
+}
+
+func sortedIDs(ids map[int]bool) []int {
+	sorted := []int{}
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Ints(sorted)
+	return sorted
+}
@@ -0,0 +1,100 @@
+package game
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"../../server/socket"
+	"../../user"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	g := createTestGame(t, Settings{ScoreLimit: 5})
+	for i := 1; i <= 4; i++ {
+		g.Join(user.User{ID: i})
+	}
+	if err := g.Start(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	for _, p := range g.Players {
+		if p.user.ID != g.judgeID {
+			g.PlayCard(p.user.ID, p.hand[0].ID)
+			break
+		}
+	}
+	g.Halt()
+
+	b, err := json.Marshal(g.Snapshot())
+	if err != nil {
+		t.Fatalf("Failed: Could not encode snapshot - %v", err)
+	}
+	var s Snapshot
+	if err := json.Unmarshal(b, &s); err != nil {
+		t.Fatalf("Failed: Could not decode snapshot - %v", err)
+	}
+	if s.Remaining <= 0 || s.Remaining > DefaultConfig().PlayDuration {
+		t.Errorf("Failed: Expected the remaining play time to be saved, got %v", s.Remaining)
+	}
+
+	restored, err := RestoreGame(s, DefaultConfig(), socket.CreateHandler())
+	if err != nil {
+		t.Fatalf("Failed: Could not restore game - %v", err)
+	}
+	defer restored.Halt()
+	checkInvariants(t, restored, "restore")
+	if !restored.IsRunning() {
+		t.Errorf("Failed: Expected the stage timer to be re-armed")
+	}
+	if len(restored.whiteIDs) != 200 || len(restored.blackIDs) != 20 {
+		t.Errorf("Failed: Expected every card to be tracked, got %d white and %d black", len(restored.whiteIDs), len(restored.blackIDs))
+	}
+	for i := 1; i <= 4; i++ {
+		if p, _ := restored.getPublicPlayer(i); p.Connected {
+			t.Errorf("Failed: Expected restored players to be disconnected until they reconnect")
+		}
+		restored.SetPresence(i, PresenceConnected)
+	}
+	for i := 1; i <= 4; i++ {
+		before, after := g.GetState(i), restored.GetState(i)
+		before.NextStage, after.NextStage = nil, nil
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("Failed: Expected player %d to see the same state after restoring\n%+v\n%+v", i, before, after)
+		}
+	}
+	if restored.nextStage.After(time.Now().Add(s.Remaining)) {
+		t.Errorf("Failed: Expected the stage to end after the remaining time, not a full stage")
+	}
+}
+
+func TestRestoreRejectsDuplicateCards(t *testing.T) {
+	g := createTestGame(t, Settings{})
+	g.Join(user.User{ID: 1})
+	s := g.Snapshot()
+	s.WhiteDiscard = append(s.WhiteDiscard, s.WhiteDraw[0])
+	if _, err := RestoreGame(s, DefaultConfig(), socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a snapshot with a duplicated card to be rejected")
+	}
+}
+
+func TestRestoreRejectsMissingCards(t *testing.T) {
+	g := startTestGame(t, Settings{}, 3)
+	g.Halt()
+	s := g.Snapshot()
+	s.WhiteDraw = s.WhiteDraw[1:]
+	if _, err := RestoreGame(s, DefaultConfig(), socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a snapshot with a missing white card to be rejected")
+	}
+	s = g.Snapshot()
+	s.BlackDiscard = nil
+	s.BlackDraw = s.BlackDraw[1:]
+	if _, err := RestoreGame(s, DefaultConfig(), socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a snapshot with a missing black card to be rejected")
+	}
+	s = g.Snapshot()
+	s.WhiteIDs = nil
+	if _, err := RestoreGame(s, DefaultConfig(), socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected a snapshot without its card IDs to be rejected")
+	}
+}
@@ -0,0 +1,148 @@
+package game
+
+import (
+	"math/rand"
+
+	"../../apperror"
+	"../../card"
+	"../../server/socket"
+)
+
+// startElimination sets up the turn order for a Survival of the Fittest judging phase,
+// starting with a different player each round
+func (g *Game) startElimination() {
+	g.eliminated = []int{}
+	g.eliminationOrder = []int{}
+	for _, p := range g.Players {
+		if !p.spectator {
+			g.eliminationOrder = append(g.eliminationOrder, p.user.ID)
+		}
+	}
+	g.eliminationTurn = 0
+	if len(g.eliminationOrder) > 0 {
+		g.eliminationTurn = g.round % len(g.eliminationOrder)
+	}
+	g.skipAbsentEliminators()
+	if !g.finishElimination() {
+		g.setStage(2, g.config.EliminationDuration)
+	}
+}
+
+// eliminate removes a submission on behalf of the player whose turn it is
+func (g *Game) eliminate(pID int, cardID int) error {
+	if pID != g.eliminatorID() {
+		return apperror.Forbidden("It is not your turn to eliminate a submission")
+	}
+	id, ok := g.getSubmissionOwner(cardID)
+	if !ok || g.isEliminated(id) {
+		return apperror.Validation("Card is not in a remaining submission")
+	}
+	if i, err := g.getPlayerIndex(pID); err == nil {
+		g.Players[i].strikes = 0
+	}
+	g.eliminateSubmission(id)
+	return nil
+}
+
+// eliminateSubmission knocks out a submission and passes the turn on, or ends judging if only one remains
+func (g *Game) eliminateSubmission(id int) {
+	g.eliminated = append(g.eliminated, id)
+	// Settle the turn on whoever took it before passing it on, in case earlier players have since left
+	g.skipAbsentEliminators()
+	g.eliminationTurn++
+	g.skipAbsentEliminators()
+	if !g.finishElimination() {
+		// Re-arming the stage gives the next player a full step and sends everyone the new state
+		g.setStage(2, g.config.EliminationDuration)
+	}
+}
+
+// finishElimination awards the round to the last remaining submission, returning whether judging is over
+func (g *Game) finishElimination() bool {
+	remaining := g.remainingSubmissions()
+	if len(remaining) > 1 {
+		return false
+	}
+	if len(remaining) == 1 {
+		g.roundWinnerIDs = remaining
+		g.awardPoint(remaining[0])
+	}
+	// Set the stage directly as the game may still be in the play stage when only one submission came in
+	g.setStage(3, g.config.ScoreDuration)
+	return true
+}
+
+// expireElimination strikes the player who did not eliminate in time and knocks out a random submission for them
+func (g *Game) expireElimination() {
+	if i, err := g.getPlayerIndex(g.eliminatorID()); err == nil {
+		g.Players[i].strikes++
+		msg := AFKMessage{UserID: g.Players[i].user.ID, Strikes: g.Players[i].strikes}
+		g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/AUTO_ELIMINATED", Payload: msg})
+	}
+	if !g.demoteIdlePlayers() {
+		return
+	}
+	remaining := g.remainingSubmissions()
+	if len(remaining) == 0 {
+		g.finishElimination()
+		return
+	}
+	g.eliminateSubmission(remaining[rand.Intn(len(remaining))])
+}
+
+// eliminatorID returns the player whose turn it is to eliminate, passing over anyone who left
+// or is spectating, or 0 if nobody can take a turn
+func (g *Game) eliminatorID() int {
+	n := len(g.eliminationOrder)
+	for j := 0; j < n; j++ {
+		if id := g.eliminationOrder[(g.eliminationTurn+j)%n]; g.canEliminate(id) {
+			return id
+		}
+	}
+	return 0
+}
+
+// skipAbsentEliminators moves the turn past anyone who left or is spectating
+func (g *Game) skipAbsentEliminators() {
+	n := len(g.eliminationOrder)
+	for j := 0; j < n && !g.canEliminate(g.eliminationOrder[g.eliminationTurn%n]); j++ {
+		g.eliminationTurn++
+	}
+}
+
+// canEliminate returns whether a player can take a turn at eliminating
+func (g *Game) canEliminate(id int) bool {
+	i, err := g.getPlayerIndex(id)
+	return err == nil && !g.Players[i].spectator
+}
+
+// remainingSubmissions returns the owners of submissions that have not been eliminated, in judging order
+func (g *Game) remainingSubmissions() []int {
+	remaining := []int{}
+	for _, id := range g.playedOrder {
+		if !g.isEliminated(id) {
+			remaining = append(remaining, id)
+		}
+	}
+	return remaining
+}
+
+func (g *Game) isEliminated(id int) bool {
+	for _, e := range g.eliminated {
+		if e == id {
+			return true
+		}
+	}
+	return false
+}
+
+// getEliminatedCards returns the submissions knocked out so far, in the order they went
+func (g *Game) getEliminatedCards() [][]card.WhiteCard {
+	cards := [][]card.WhiteCard{}
+	for _, id := range g.eliminated {
+		if c, ok := g.whitePlayed[id]; ok {
+			cards = append(cards, c)
+		}
+	}
+	return cards
+}
@@ -0,0 +1,121 @@
+package game
+
+import (
+	"testing"
+
+	"../../card"
+	"../../server/socket"
+)
+
+func TestSurvivalConflictsWithGodIsDead(t *testing.T) {
+	settings := Settings{HouseRules: []string{RuleGodIsDead, RuleSurvival}}
+	if _, err := CreateGame("Test", 10, settings, DefaultConfig(), []card.WhiteCard{}, []card.BlackCard{}, socket.CreateHandler()); err == nil {
+		t.Errorf("Failed: Expected God Is Dead and Survival of the Fittest to be rejected together")
+	}
+}
+
+func TestSurvivalOfTheFittest(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleSurvival}}, 4)
+	defer g.Halt()
+	playFor(g)
+	if g.judgeID != 0 || g.stage != 2 || len(g.playedOrder) != 4 {
+		t.Fatalf("Failed: Expected every player's submission to be judged without a judge, stage %d", g.stage)
+	}
+
+	for step := 1; step <= 3; step++ {
+		eliminatorID := g.GetState(1).EliminatorID
+		for _, p := range g.Players {
+			if p.user.ID != eliminatorID && g.VoteCard(p.user.ID, g.whitePlayed[g.remainingSubmissions()[0]][0].ID) == nil {
+				t.Fatalf("Failed: Expected player %d to wait for their turn", p.user.ID)
+			}
+		}
+		target := g.remainingSubmissions()[0]
+		deadline := *g.nextStage
+		if err := g.VoteCard(eliminatorID, g.whitePlayed[target][0].ID); err != nil {
+			t.Fatalf("Failed: Player %d could not eliminate - %v", eliminatorID, err)
+		}
+		if step == 3 {
+			break
+		}
+		state := g.GetState(target)
+		if len(state.EliminatedCards) != step || len(state.WhiteCardsUnknown) != 4-step {
+			t.Errorf("Failed: Expected %d eliminated submissions, got %d and %d remaining", step, len(state.EliminatedCards), len(state.WhiteCardsUnknown))
+		}
+		if state.EliminatorID == eliminatorID || !g.nextStage.After(deadline) {
+			t.Errorf("Failed: Expected the turn to pass on with a fresh timer")
+		}
+		if err := g.VoteCard(state.EliminatorID, g.whitePlayed[target][0].ID); err == nil {
+			t.Errorf("Failed: Expected an eliminated submission to stay out")
+		}
+	}
+
+	if g.stage != 3 || g.roundWinnerID() == 0 || totalScore(g) != 1 {
+		t.Errorf("Failed: Expected the last submission standing to win, stage %d winner %d", g.stage, g.roundWinnerID())
+	}
+	checkInvariants(t, g, "elimination")
+}
+
+func TestSurvivalDeadlineEliminatesAtRandom(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleSurvival}}, 3)
+	defer g.Halt()
+	playFor(g)
+	eliminatorID := g.eliminatorID()
+	expire(g)
+	if len(g.eliminated) != 1 || g.eliminatorID() == eliminatorID {
+		t.Errorf("Failed: Expected a submission to be eliminated for the idle player and the turn to pass on")
+	}
+	if p, _ := g.getPrivatePlayer(eliminatorID); p.strikes != 1 {
+		t.Errorf("Failed: Expected the idle player to get a strike, has %d", p.strikes)
+	}
+	expire(g)
+	if g.stage != 3 || totalScore(g) != 1 {
+		t.Errorf("Failed: Expected the round to be won once one submission remains, stage %d", g.stage)
+	}
+	checkInvariants(t, g, "elimination deadline")
+}
+
+func TestSurvivalWithSingleSubmission(t *testing.T) {
+	settings := Settings{HouseRules: []string{RuleSurvival}, AFKPolicy: AFKSkip}
+	g := startTestGame(t, settings, 3, pickCards(1)...)
+	defer g.Halt()
+	if err := g.PlayCard(1, g.Players[0].hand[0].ID); err != nil {
+		t.Fatalf("Failed: Could not play card - %v", err)
+	}
+	expire(g)
+	if g.stage != 3 || g.roundWinnerID() != 1 || totalScore(g) != 1 {
+		t.Errorf("Failed: Expected the only submission to win straight away, stage %d winner %d", g.stage, g.roundWinnerID())
+	}
+	checkInvariants(t, g, "single submission")
+}
+
+func TestEliminatorLeaving(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleSurvival}}, 5, pickCards(1)...)
+	defer g.Halt()
+	playFor(g)
+	order := append([]int{}, g.eliminationOrder...)
+	after := func(id int) int {
+		for i, o := range order {
+			if o == id {
+				return order[(i+1)%len(order)]
+			}
+		}
+		return 0
+	}
+	leaverID := g.eliminatorID()
+	turn := g.eliminationTurn
+	g.Leave(leaverID)
+	nextID := g.GetState(after(leaverID)).EliminatorID
+	if g.eliminationTurn != turn || nextID != after(leaverID) {
+		t.Fatalf("Failed: Expected the state to show player %d without moving the turn, got %d", after(leaverID), nextID)
+	}
+	target := g.remainingSubmissions()[0]
+	if target == nextID {
+		target = g.remainingSubmissions()[1]
+	}
+	if err := g.VoteCard(nextID, g.whitePlayed[target][0].ID); err != nil {
+		t.Fatalf("Failed: Player %d could not eliminate - %v", nextID, err)
+	}
+	if id := g.eliminatorID(); id != after(nextID) {
+		t.Errorf("Failed: Expected the turn to pass from %d to %d, got %d", nextID, after(nextID), id)
+	}
+}
@@ -0,0 +1,132 @@
+package game
+
+import (
+	"math/rand"
+
+	"../../apperror"
+	"../../server/socket"
+)
+
+// castVote records a player's vote in a God Is Dead game, tallying the votes once everyone has voted.
+// Players may change their vote until the tally.
+func (g *Game) castVote(voterID int, cardID int) error {
+	i, err := g.getPlayerIndex(voterID)
+	if err != nil {
+		return err
+	}
+	if g.Players[i].spectator {
+		return apperror.Forbidden("Spectators cannot vote")
+	}
+	id, ok := g.getSubmissionOwner(cardID)
+	if !ok {
+		return apperror.Validation("Card was not played this round")
+	}
+	if id == voterID {
+		return apperror.Validation("You cannot vote for your own cards")
+	}
+	g.votes[voterID] = id
+	g.Players[i].strikes = 0
+	if g.allVotesIn() {
+		g.tallyVotes()
+		g.next()
+	} else {
+		g.updateUserStates()
+	}
+	return nil
+}
+
+// allVotesIn returns whether every active player with someone else's submission to vote for has voted
+func (g *Game) allVotesIn() bool {
+	for _, p := range g.Players {
+		if _, voted := g.votes[p.user.ID]; !voted && !p.spectator && g.canVote(p.user.ID) {
+			return false
+		}
+	}
+	return true
+}
+
+// canVote returns whether there is a submission from someone other than the player
+func (g *Game) canVote(pID int) bool {
+	for _, id := range g.playedOrder {
+		if id != pID {
+			return true
+		}
+	}
+	return false
+}
+
+// removeVotes forgets the vote of a player who left and any votes for their submission, letting those voters choose again
+func (g *Game) removeVotes(pID int) {
+	delete(g.votes, pID)
+	for voterID, id := range g.votes {
+		if id == pID {
+			delete(g.votes, voterID)
+		}
+	}
+}
+
+// expireVoting strikes players who did not vote in time, then scores the votes that were cast
+func (g *Game) expireVoting() {
+	for i, p := range g.Players {
+		if _, voted := g.votes[p.user.ID]; voted || p.spectator || !g.canVote(p.user.ID) {
+			continue
+		}
+		g.Players[i].strikes++
+		msg := AFKMessage{UserID: p.user.ID, Strikes: g.Players[i].strikes}
+		g.socketHandler.SendActionToUsers(g.getPlayerIDs(), socket.Action{Type: "game/VOTE_SKIPPED", Payload: msg})
+	}
+	if g.demoteIdlePlayers() {
+		g.tallyVotes()
+		g.next()
+	}
+}
+
+// tallyVotes awards points to the submission with the most votes, settling ties with the game's tie policy
+func (g *Game) tallyVotes() {
+	tally := g.getVoteTally()
+	most := 0
+	for _, n := range tally {
+		if n > most {
+			most = n
+		}
+	}
+	if most == 0 {
+		return
+	}
+	tied := []int{}
+	for _, id := range g.playedOrder {
+		if tally[id] == most {
+			tied = append(tied, id)
+		}
+	}
+
+	switch {
+	case len(tied) == 1:
+		g.roundWinnerIDs = tied
+		g.awardPoint(tied[0])
+	case g.settings.TiePolicy == TieShare:
+		g.roundWinnerIDs = tied
+		for _, id := range tied {
+			g.awardPoint(id)
+		}
+	case g.settings.TiePolicy == TieNone:
+	default:
+		id := tied[rand.Intn(len(tied))]
+		g.roundWinnerIDs = []int{id}
+		g.awardPoint(id)
+	}
+}
+
+// getVoteTally counts the votes for each submission this round
+func (g *Game) getVoteTally() map[int]int {
+	tally := make(map[int]int)
+	for _, id := range g.playedOrder {
+		tally[id] = 0
+	}
+	for _, id := range g.votes {
+		if _, ok := tally[id]; ok {
+			tally[id]++
+		}
+	}
+	return tally
+}
@@ -0,0 +1,123 @@
+package game
+
+import "testing"
+
+// voteAll has each player vote for the submission of the player it maps to
+func voteAll(t *testing.T, g *Game, votes map[int]int) {
+	for voterID := 1; voterID <= len(votes); voterID++ {
+		if err := g.VoteCard(voterID, g.whitePlayed[votes[voterID]][0].ID); err != nil {
+			t.Fatalf("Failed: Player %d could not vote - %v", voterID, err)
+		}
+	}
+}
+
+func totalScore(g *Game) int {
+	total := 0
+	for _, p := range g.Players {
+		total += p.score
+	}
+	return total
+}
+
+func TestGodIsDeadVoting(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleGodIsDead}}, 4)
+	defer g.Halt()
+	if g.judgeID != 0 {
+		t.Fatalf("Failed: Expected no judge, got %d", g.judgeID)
+	}
+	playFor(g)
+	if g.stage != 2 {
+		t.Fatalf("Failed: Expected voting once everyone played, stage is %d", g.stage)
+	}
+	for i := 1; i <= 4; i++ {
+		if n := len(g.GetState(i).WhiteCardsUnknown); n != 3 {
+			t.Errorf("Failed: Expected player %d to see the 3 other submissions anonymously, sees %d", i, n)
+		}
+	}
+	if err := g.VoteCard(1, g.whitePlayed[1][0].ID); err == nil {
+		t.Errorf("Failed: Expected a vote for your own cards to be rejected")
+	}
+
+	voteAll(t, g, map[int]int{1: 2, 2: 3, 3: 2, 4: 2})
+	if g.stage != 3 || g.roundWinnerID() != 2 {
+		t.Fatalf("Failed: Expected player 2 to win with the most votes, stage %d winner %d", g.stage, g.roundWinnerID())
+	}
+	if p, _ := g.getPrivatePlayer(2); p.score != 1 || totalScore(g) != 1 {
+		t.Errorf("Failed: Expected only the plurality winner to score")
+	}
+	tally := g.GetState(1).VoteTally
+	if tally[2] != 3 || tally[3] != 1 || tally[1] != 0 {
+		t.Errorf("Failed: Expected the vote tally in the scoring state, got %v", tally)
+	}
+	checkInvariants(t, g, "voting")
+}
+
+func TestGodIsDeadTies(t *testing.T) {
+	expected := map[string]int{TieShare: 4, TieNone: 0, TieRandom: 1}
+	for policy, points := range expected {
+		g := startTestGame(t, Settings{HouseRules: []string{RuleGodIsDead}, TiePolicy: policy}, 4)
+		playFor(g)
+		voteAll(t, g, map[int]int{1: 2, 2: 1, 3: 4, 4: 3})
+		if total := totalScore(g); total != points {
+			t.Errorf("Failed: Expected %d points for a four way tie with the %s policy, got %d", points, policy, total)
+		}
+		g.Halt()
+	}
+}
+
+func TestSharedTieRecordsEveryWinner(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleGodIsDead}, TiePolicy: TieShare, JudgeRotation: RotationWinner}, 4)
+	defer g.Halt()
+	playFor(g)
+	voteAll(t, g, map[int]int{1: 2, 2: 1, 3: 2, 4: 1})
+	winners := g.GetState(3).RoundWinnerIDs
+	if len(winners) != 2 {
+		t.Fatalf("Failed: Expected both tied players as round winners, got %v", winners)
+	}
+	for _, id := range winners {
+		if id != 1 && id != 2 {
+			t.Errorf("Failed: Expected only players 1 and 2 to win the round, got %v", winners)
+		}
+	}
+	if judgeID := g.chooseJudge(4); judgeID != 1 && judgeID != 2 {
+		t.Errorf("Failed: Expected the next judge to be one of the tied winners, got %d", judgeID)
+	}
+}
+
+func TestGodIsDeadVotingDeadline(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleGodIsDead}}, 4)
+	defer g.Halt()
+	playFor(g)
+	g.VoteCard(1, g.whitePlayed[3][0].ID)
+	expire(g)
+	if g.stage != 3 || g.roundWinnerID() != 3 {
+		t.Errorf("Failed: Expected the votes cast before the deadline to count, winner %d", g.roundWinnerID())
+	}
+	for _, p := range g.Players {
+		if expected := map[bool]int{true: 0, false: 1}[p.user.ID == 1]; p.strikes != expected {
+			t.Errorf("Failed: Expected player %d to have %d strikes, has %d", p.user.ID, expected, p.strikes)
+		}
+	}
+}
+
+func TestGodIsDeadPlayerLeaving(t *testing.T) {
+	g := startTestGame(t, Settings{HouseRules: []string{RuleGodIsDead}}, 5)
+	defer g.Halt()
+	playFor(g)
+	for voterID, id := range map[int]int{1: 5, 2: 3, 3: 2, 4: 2} {
+		if err := g.VoteCard(voterID, g.whitePlayed[id][0].ID); err != nil {
+			t.Fatalf("Failed: Player %d could not vote - %v", voterID, err)
+		}
+	}
+	g.Leave(5)
+	if g.stage != 2 {
+		t.Fatalf("Failed: Expected player 1 to vote again once their choice left, stage %d", g.stage)
+	}
+	if err := g.VoteCard(1, g.whitePlayed[2][0].ID); err != nil {
+		t.Fatalf("Failed: Player 1 could not vote again - %v", err)
+	}
+	if g.stage != 3 || g.roundWinnerID() != 2 {
+		t.Errorf("Failed: Expected player 2 to win once the votes were in, stage %d winner %d", g.stage, g.roundWinnerID())
+	}
+	checkInvariants(t, g, "voter leaving")
+}
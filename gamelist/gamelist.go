@@ -1,37 +1,53 @@
 package gamelist
 
 import (
-	"errors"
+	"sync"
+	"time"
 
+	"../apperror"
 	"../card"
 	"../server/socket"
 	"../user"
 	"./game"
 )
 
-// GameList a group of games where each game has a unique name
+// GameList a group of games where each game has a unique name, safe for concurrent use
 type GameList struct {
+	mu            sync.Mutex
 	socketHandler *socket.Handler
+	config        game.Config
 	gamesByName   map[string]*game.Game
 	gamesByUserID map[int]*game.Game
+	removals      map[int]*time.Timer // Pending removals of disconnected players, by user ID
+	presenceSeqs  map[int]int         // Sequence number of the latest connection to change each user's presence
+	draining      bool
+	halted        bool
 }
 
 // CreateGameList constructor, generates an empty game list
-func CreateGameList(socketHandler *socket.Handler) GameList {
-	return GameList{
+func CreateGameList(socketHandler *socket.Handler, config game.Config) *GameList {
+	return &GameList{
 		socketHandler: socketHandler,
+		config:        config,
 		gamesByName:   make(map[string]*game.Game),
 		gamesByUserID: make(map[int]*game.Game),
+		removals:      make(map[int]*time.Timer),
+		presenceSeqs:  make(map[int]int),
 	}
 }
 
 // CreateGame creates a new game with the given name and cards
-func (gl *GameList) CreateGame(u user.User, name string, maxPlayers int, bc []card.BlackCard, wc []card.WhiteCard) error {
+func (gl *GameList) CreateGame(u user.User, name string, maxPlayers int, settings game.Settings, bc []card.BlackCard, wc []card.WhiteCard) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if gl.draining {
+		return apperror.InvalidState("Server is shutting down")
+	}
 	if _, exists := gl.gamesByName[name]; exists {
-		return errors.New("Game name is taken")
+		return apperror.InvalidState("Game name is taken")
 	}
-	gl.LeaveGame(u)
-	game, err := game.CreateGame(name, maxPlayers, wc, bc, gl.socketHandler)
+	gl.leaveGame(u)
+	game, err := game.CreateGame(name, maxPlayers, settings, gl.config, wc, bc, gl.socketHandler)
 	if err != nil {
 		return err
 	}
@@ -43,6 +59,11 @@ func (gl *GameList) CreateGame(u user.User, name string, maxPlayers int, bc []ca
 
 // StartGame starts the game that the user is in (if they are the game owner)
 func (gl *GameList) StartGame(uID int) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if gl.draining {
+		return apperror.InvalidState("Server is shutting down")
+	}
 	if userGame, exists := gl.gamesByUserID[uID]; exists {
 		err := userGame.Start(uID)
 		if err != nil {
@@ -50,11 +71,13 @@ func (gl *GameList) StartGame(uID int) error {
 		}
 		return nil
 	}
-	return errors.New("User is not in a game")
+	return apperror.NotFound("User is not in a game")
 }
 
 // StopGame starts the game that the user is in (if they are the game owner)
 func (gl *GameList) StopGame(uID int) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	if userGame, exists := gl.gamesByUserID[uID]; exists {
 		err := userGame.Stop(uID)
 		if err != nil {
@@ -62,11 +85,13 @@ func (gl *GameList) StopGame(uID int) error {
 		}
 		return nil
 	}
-	return errors.New("User is not in a game")
+	return apperror.NotFound("User is not in a game")
 }
 
 // GetStateForUser returns a game state from the perspective of a particular user
 func (gl *GameList) GetStateForUser(u user.User) *game.UserState {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	userGame, exists := gl.gamesByUserID[u.ID]
 	if !exists {
 		return nil
@@ -77,20 +102,23 @@ func (gl *GameList) GetStateForUser(u user.User) *game.UserState {
 
 // JoinGame adds a user to a particular game
 func (gl *GameList) JoinGame(u user.User, gn string) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	oldGame, _ := gl.gamesByUserID[u.ID]
 	newGame, _ := gl.gamesByName[gn]
 	if newGame == nil {
-		return errors.New("Game does not exist")
+		return apperror.NotFound("Game does not exist")
 	}
 	if oldGame != nil && oldGame.Name == newGame.Name {
-		return errors.New("You are already in this game")
+		return apperror.InvalidState("You are already in this game")
 	}
-	if len(newGame.Players) >= newGame.MaxPlayers {
-		return errors.New("Game is full")
+	if newGame.PlayerCount() >= newGame.MaxPlayers {
+		return apperror.InvalidState("Game is full")
 	}
-	if oldGame == nil {
-		gl.LeaveGame(u)
+	if oldGame != nil {
+		gl.leaveGame(u)
 	}
+	gl.cancelRemoval(u.ID)
 	newGame.Join(u)
 	gl.gamesByUserID[u.ID] = newGame
 	return nil
@@ -98,41 +126,269 @@ func (gl *GameList) JoinGame(u user.User, gn string) error {
 
 // LeaveGame removes a user from a particular game
 func (gl *GameList) LeaveGame(u user.User) {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	gl.leaveGame(u)
+}
+
+func (gl *GameList) leaveGame(u user.User) {
+	gl.cancelRemoval(u.ID)
 	if game, inGame := gl.gamesByUserID[u.ID]; inGame {
 		game.Leave(u.ID)
 		delete(gl.gamesByUserID, u.ID)
-		if len(game.Players) == 0 {
+		if game.PlayerCount() == 0 {
 			delete(gl.gamesByName, game.Name)
 		}
 	}
 }
 
 // KickUser kicks a user from the game if the kicker is the game owner
-func (gl *GameList) KickUser(owner user.User, uID int) {
+func (gl *GameList) KickUser(owner user.User, uID int) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	if game, inGame := gl.gamesByUserID[owner.ID]; inGame {
-		game.KickUser(owner.ID, uID)
+		if err := game.KickUser(owner.ID, uID); err != nil {
+			return err
+		}
+		delete(gl.gamesByUserID, uID)
+		gl.cancelRemoval(uID)
+		return nil
 	}
+	return apperror.NotFound("User is not in a game")
 }
 
 // PlayCard allows user to play a card if they are not the judge
-func (gl *GameList) PlayCard(u user.User, cID int) {
+func (gl *GameList) PlayCard(u user.User, cID int) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	if game, inGame := gl.gamesByUserID[u.ID]; inGame {
-		game.PlayCard(u.ID, cID)
+		return game.PlayCard(u.ID, cID)
 	}
+	return apperror.NotFound("User is not in a game")
 }
 
 // VoteCard allows user to pick a favorite card
-func (gl *GameList) VoteCard(judge user.User, cID int) {
+func (gl *GameList) VoteCard(judge user.User, cID int) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	if game, inGame := gl.gamesByUserID[judge.ID]; inGame {
-		game.VoteCard(judge.ID, cID)
+		return game.VoteCard(judge.ID, cID)
+	}
+	return apperror.NotFound("User is not in a game")
+}
+
+// ResumePlaying lets a user who was moved to spectating for being idle take part again
+func (gl *GameList) ResumePlaying(u user.User) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if game, inGame := gl.gamesByUserID[u.ID]; inGame {
+		return game.Resume(u.ID)
+	}
+	return apperror.NotFound("User is not in a game")
+}
+
+// RebootHand trades one of a user's points for a new hand
+func (gl *GameList) RebootHand(u user.User) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if game, inGame := gl.gamesByUserID[u.ID]; inGame {
+		return game.RebootHand(u.ID)
 	}
+	return apperror.NotFound("User is not in a game")
+}
+
+// DeclareHappyEnding lets the owner of a user's game call the final round
+func (gl *GameList) DeclareHappyEnding(u user.User) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if game, inGame := gl.gamesByUserID[u.ID]; inGame {
+		return game.DeclareHappyEnding(u.ID)
+	}
+	return apperror.NotFound("User is not in a game")
+}
+
+// DiscardCard lets a user throw away a card they do not understand
+func (gl *GameList) DiscardCard(u user.User, cID int) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if game, inGame := gl.gamesByUserID[u.ID]; inGame {
+		return game.DiscardCard(u.ID, cID)
+	}
+	return apperror.NotFound("User is not in a game")
 }
 
 // GetList fetches a list of all current games
 func (gl *GameList) GetList() []game.GenericState {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
 	list := []game.GenericState{}
 	for _, game := range gl.gamesByName {
 		list = append(list, game.GetGenericState())
 	}
 	return list
 }
+
+// Drain stops new games from being created or started so the server can shut down
+func (gl *GameList) Drain() {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	gl.draining = true
+}
+
+// RunningGames returns the number of games with a round in progress
+func (gl *GameList) RunningGames() int {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	count := 0
+	for _, game := range gl.gamesByName {
+		if game.IsRunning() {
+			count++
+		}
+	}
+	return count
+}
+
+// HaltBetweenRounds halts every game that is not in the middle of a round, so draining games are frozen as soon as
+// their round is scored, and returns the number of games still playing a round
+func (gl *GameList) HaltBetweenRounds() int {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	count := 0
+	for _, game := range gl.gamesByName {
+		if !game.HaltBetweenRounds() {
+			count++
+		}
+	}
+	return count
+}
+
+// HaltAll stops the timers of every game and stops removing disconnected players
+func (gl *GameList) HaltAll() {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	gl.halted = true
+	for _, game := range gl.gamesByName {
+		game.Halt()
+	}
+	for uID := range gl.removals {
+		gl.cancelRemoval(uID)
+	}
+}
+
+// Connect marks a user as connected in their game, cancelling their removal if they had disconnected.
+// seq is the socket handler's sequence number for the connection, connections older than the latest are ignored.
+func (gl *GameList) Connect(u user.User, seq int) {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if seq <= gl.presenceSeqs[u.ID] {
+		return
+	}
+	gl.presenceSeqs[u.ID] = seq
+	gl.cancelRemoval(u.ID)
+	if g, inGame := gl.gamesByUserID[u.ID]; inGame {
+		g.SetPresence(u.ID, game.PresenceConnected)
+	}
+}
+
+// Disconnect marks a user as disconnected in their game when the connection with sequence number seq closes,
+// removing them if they have not reconnected by the end of the grace period. Nothing changes if the user
+// has another socket open or has connected again since.
+func (gl *GameList) Disconnect(u user.User, seq int) {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if seq < gl.presenceSeqs[u.ID] || gl.socketHandler.IsConnected(u.ID) {
+		return
+	}
+	gl.presenceSeqs[u.ID] = seq
+	if g, inGame := gl.gamesByUserID[u.ID]; inGame {
+		g.SetPresence(u.ID, game.PresenceDisconnected)
+		gl.scheduleRemoval(u)
+	}
+}
+
+// SetAway marks a connected user as away from (or back at) their game
+func (gl *GameList) SetAway(u user.User, away bool) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	if g, inGame := gl.gamesByUserID[u.ID]; inGame {
+		if away {
+			return g.SetPresence(u.ID, game.PresenceAway)
+		}
+		return g.SetPresence(u.ID, game.PresenceConnected)
+	}
+	return apperror.NotFound("User is not in a game")
+}
+
+// scheduleRemoval removes a user from their game once the reconnect grace period passes
+func (gl *GameList) scheduleRemoval(u user.User) {
+	if gl.halted {
+		return
+	}
+	gl.cancelRemoval(u.ID)
+	var timer *time.Timer
+	timer = time.AfterFunc(gl.config.ReconnectGrace, func() {
+		gl.mu.Lock()
+		defer gl.mu.Unlock()
+		if gl.removals[u.ID] == timer {
+			gl.leaveGame(u)
+		}
+	})
+	gl.removals[u.ID] = timer
+}
+
+func (gl *GameList) cancelRemoval(uID int) {
+	if timer, ok := gl.removals[uID]; ok {
+		timer.Stop()
+		delete(gl.removals, uID)
+	}
+}
+
+// Snapshot captures the state of every game so they can be restored after a restart
+func (gl *GameList) Snapshot() []game.Snapshot {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	snapshots := []game.Snapshot{}
+	for _, game := range gl.gamesByName {
+		snapshots = append(snapshots, game.Snapshot())
+	}
+	return snapshots
+}
+
+// Restore recreates games from snapshots, skipping any that clash with existing games or players.
+// Players are re-associated with their game by user ID, so they pick it up again when they reconnect
+// within the grace period.
+func (gl *GameList) Restore(snapshots []game.Snapshot) error {
+	gl.mu.Lock()
+	defer gl.mu.Unlock()
+	var firstErr error
+	for _, s := range snapshots {
+		if err := gl.restoreGame(s); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (gl *GameList) restoreGame(s game.Snapshot) error {
+	if _, exists := gl.gamesByName[s.Name]; exists {
+		return apperror.InvalidState("Game name is taken - " + s.Name)
+	}
+	if len(s.Players) == 0 {
+		return apperror.Validation("Snapshot has no players - " + s.Name)
+	}
+	for _, p := range s.Players {
+		if _, inGame := gl.gamesByUserID[p.User.ID]; inGame {
+			return apperror.InvalidState("Player is already in a game - " + s.Name)
+		}
+	}
+	restored, err := game.RestoreGame(s, gl.config, gl.socketHandler)
+	if err != nil {
+		return err
+	}
+	gl.gamesByName[s.Name] = restored
+	for _, p := range s.Players {
+		gl.gamesByUserID[p.User.ID] = restored
+		gl.scheduleRemoval(p.User)
+	}
+	return nil
+}
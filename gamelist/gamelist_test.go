@@ -0,0 +1,289 @@
+package gamelist
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googollee/go-socket.io"
+
+	"../apperror"
+	"../card"
+	"../server/socket"
+	"../user"
+	"./game"
+)
+
+func createTestCards() ([]card.BlackCard, []card.WhiteCard) {
+	bc := []card.BlackCard{}
+	for i := 1; i <= 20; i++ {
+		bc = append(bc, card.CreateBlackCard(i, "Black", 1, 1))
+	}
+	wc := []card.WhiteCard{}
+	for i := 1; i <= 300; i++ {
+		wc = append(wc, card.CreateWhiteCard(i, "White", 1))
+	}
+	return bc, wc
+}
+
+func TestConcurrentPlayers(t *testing.T) {
+	gl := CreateGameList(socket.CreateHandler(), game.DefaultConfig())
+	bc, wc := createTestCards()
+	owner := user.User{ID: 1}
+	if err := gl.CreateGame(owner, "Test", 20, game.Settings{}, bc, wc); err != nil {
+		t.Fatalf("Failed: Could not create game - %v", err)
+	}
+	for i := 2; i <= 4; i++ {
+		gl.JoinGame(user.User{ID: i}, "Test")
+	}
+	if err := gl.StartGame(owner.ID); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 2; i <= 20; i++ {
+		wg.Add(1)
+		go func(u user.User) {
+			defer wg.Done()
+			r := rand.New(rand.NewSource(int64(u.ID)))
+			for step := 0; step < 100; step++ {
+				switch r.Intn(5) {
+				case 0:
+					gl.JoinGame(u, "Test")
+				case 1:
+					gl.LeaveGame(u)
+				case 2:
+					if state := gl.GetStateForUser(u); state != nil && len(state.Hand) > 0 {
+						gl.PlayCard(u, state.Hand[0].ID)
+					}
+				case 3:
+					if state := gl.GetStateForUser(u); state != nil && len(state.WhiteCardsUnknown) > 0 {
+						gl.VoteCard(u, state.WhiteCardsUnknown[0][0].ID)
+					}
+				case 4:
+					gl.GetList()
+				}
+			}
+		}(user.User{ID: i, Name: fmt.Sprint("Player ", i)})
+	}
+	wg.Wait()
+
+	gl.StopGame(owner.ID)
+	if userGame, ok := gl.gamesByName["Test"]; ok {
+		if err := userGame.CheckInvariants(); err != nil {
+			t.Errorf("Failed: %v", err)
+		}
+	}
+}
+
+func TestDrainBlocksNewGames(t *testing.T) {
+	gl := CreateGameList(socket.CreateHandler(), game.DefaultConfig())
+	bc, wc := createTestCards()
+	gl.CreateGame(user.User{ID: 1}, "Running", 10, game.Settings{}, bc, wc)
+	for i := 2; i <= 3; i++ {
+		gl.JoinGame(user.User{ID: i}, "Running")
+	}
+	gl.StartGame(1)
+	gl.CreateGame(user.User{ID: 4}, "Waiting", 10, game.Settings{}, bc, wc)
+
+	gl.Drain()
+	if err := gl.CreateGame(user.User{ID: 5}, "New", 10, game.Settings{}, bc, wc); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected creating a game while draining to fail, got %v", err)
+	}
+	if err := gl.StartGame(4); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected starting a game while draining to fail, got %v", err)
+	}
+	if n := gl.RunningGames(); n != 1 {
+		t.Errorf("Failed: Expected 1 running game, got %d", n)
+	}
+	gl.HaltAll()
+}
+
+func TestDrainHaltsGamesBetweenRounds(t *testing.T) {
+	config := game.DefaultConfig()
+	config.ScoreDuration = 10 * time.Millisecond
+	gl := CreateGameList(socket.CreateHandler(), config)
+	defer gl.HaltAll()
+	bc, wc := createTestCards()
+	gl.CreateGame(user.User{ID: 1}, "Running", 10, game.Settings{}, bc, wc)
+	for i := 2; i <= 3; i++ {
+		gl.JoinGame(user.User{ID: i}, "Running")
+	}
+	gl.StartGame(1)
+	gl.CreateGame(user.User{ID: 4}, "Waiting", 10, game.Settings{}, bc, wc)
+	gl.Drain()
+
+	if n := gl.HaltBetweenRounds(); n != 1 {
+		t.Fatalf("Failed: Expected 1 game to be mid-round, got %d", n)
+	}
+	judgeID := gl.GetStateForUser(user.User{ID: 1}).JudgeID
+	for i := 1; i <= 3; i++ {
+		if u := (user.User{ID: i}); i != judgeID {
+			gl.PlayCard(u, gl.GetStateForUser(u).Hand[0].ID)
+		}
+	}
+	state := gl.GetStateForUser(user.User{ID: judgeID})
+	if err := gl.VoteCard(user.User{ID: judgeID}, state.WhiteCardsUnknown[0][0].ID); err != nil {
+		t.Fatalf("Failed: Could not vote - %v", err)
+	}
+	if n := gl.HaltBetweenRounds(); n != 0 {
+		t.Fatalf("Failed: Expected no games mid-round once the round was scored, got %d", n)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if stage := gl.GetStateForUser(user.User{ID: 1}).CurrentStage; stage != 3 {
+		t.Errorf("Failed: Expected the game to be frozen after scoring, stage is %d", stage)
+	}
+}
+
+func TestRestoreReassociatesPlayers(t *testing.T) {
+	gl := CreateGameList(socket.CreateHandler(), game.DefaultConfig())
+	bc, wc := createTestCards()
+	if err := gl.CreateGame(user.User{ID: 1}, "Test", 10, game.Settings{}, bc, wc); err != nil {
+		t.Fatalf("Failed: Could not create game - %v", err)
+	}
+	for i := 2; i <= 3; i++ {
+		gl.JoinGame(user.User{ID: i}, "Test")
+	}
+	if err := gl.StartGame(1); err != nil {
+		t.Fatalf("Failed: Could not start game - %v", err)
+	}
+	gl.HaltAll()
+
+	restored := CreateGameList(socket.CreateHandler(), game.DefaultConfig())
+	if err := restored.Restore(gl.Snapshot()); err != nil {
+		t.Fatalf("Failed: Could not restore games - %v", err)
+	}
+	defer restored.HaltAll()
+	for i := 1; i <= 3; i++ {
+		state := restored.GetStateForUser(user.User{ID: i})
+		if state == nil || state.Name != "Test" || len(state.Hand) != 10 {
+			t.Errorf("Failed: Expected player %d to rejoin their game with their hand, got %+v", i, state)
+		}
+	}
+	if restored.RunningGames() != 1 {
+		t.Errorf("Failed: Expected the restored game to be running")
+	}
+	if err := restored.Restore(gl.Snapshot()); err == nil {
+		t.Errorf("Failed: Expected restoring a game twice to be rejected")
+	}
+}
+
+func TestDisconnectedPlayersAreRemovedAfterGrace(t *testing.T) {
+	config := game.DefaultConfig()
+	config.ReconnectGrace = 20 * time.Millisecond
+	gl := CreateGameList(socket.CreateHandler(), config)
+	bc, wc := createTestCards()
+	if err := gl.CreateGame(user.User{ID: 1}, "Test", 10, game.Settings{}, bc, wc); err != nil {
+		t.Fatalf("Failed: Could not create game - %v", err)
+	}
+	for i := 2; i <= 3; i++ {
+		gl.JoinGame(user.User{ID: i}, "Test")
+	}
+
+	gl.Connect(user.User{ID: 2}, 1)
+	gl.Connect(user.User{ID: 3}, 2)
+	gl.Disconnect(user.User{ID: 2}, 1)
+	gl.Disconnect(user.User{ID: 3}, 2)
+	for _, p := range gl.GetStateForUser(user.User{ID: 1}).Players {
+		if p.User.ID != 1 && (p.Connected || p.Presence != game.PresenceDisconnected) {
+			t.Errorf("Failed: Expected player %d to be shown as disconnected", p.User.ID)
+		}
+	}
+	gl.Connect(user.User{ID: 2}, 3)
+	time.Sleep(100 * time.Millisecond)
+
+	if gl.GetStateForUser(user.User{ID: 2}) == nil {
+		t.Errorf("Failed: Expected a player who reconnected in time to keep their seat")
+	}
+	if gl.GetStateForUser(user.User{ID: 3}) != nil {
+		t.Errorf("Failed: Expected a player who stayed disconnected to be removed")
+	}
+	if err := gl.SetAway(user.User{ID: 2}, true); err != nil {
+		t.Fatalf("Failed: Could not set player away - %v", err)
+	}
+	for _, p := range gl.GetStateForUser(user.User{ID: 1}).Players {
+		if p.User.ID == 2 && (!p.Connected || p.Presence != game.PresenceAway) {
+			t.Errorf("Failed: Expected player 2 to be shown as away, got %+v", p)
+		}
+	}
+}
+
+func TestKickedPlayerKeepsTheirNextGame(t *testing.T) {
+	config := game.DefaultConfig()
+	config.ReconnectGrace = 20 * time.Millisecond
+	gl := CreateGameList(socket.CreateHandler(), config)
+	bc, wc := createTestCards()
+	for i, name := range []string{"Test", "Other"} {
+		if err := gl.CreateGame(user.User{ID: i + 1}, name, 10, game.Settings{}, bc, wc); err != nil {
+			t.Fatalf("Failed: Could not create game - %v", err)
+		}
+	}
+	gl.JoinGame(user.User{ID: 3}, "Test")
+	gl.Connect(user.User{ID: 3}, 1)
+	gl.Disconnect(user.User{ID: 3}, 1)
+	if err := gl.KickUser(user.User{ID: 1}, 3); err != nil {
+		t.Fatalf("Failed: Could not kick player - %v", err)
+	}
+	if err := gl.JoinGame(user.User{ID: 3}, "Other"); err != nil {
+		t.Fatalf("Failed: Could not join another game - %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	if s := gl.GetStateForUser(user.User{ID: 3}); s == nil || s.Name != "Other" {
+		t.Errorf("Failed: Expected the kicked player's pending removal to be cancelled, got %+v", s)
+	}
+}
+
+// quietSocket ignores everything sent to it
+type quietSocket struct{ id string }
+
+func (s *quietSocket) Id() string                                      { return s.id }
+func (s *quietSocket) Rooms() []string                                 { return nil }
+func (s *quietSocket) Request() *http.Request                          { return nil }
+func (s *quietSocket) On(event string, f interface{}) error            { return nil }
+func (s *quietSocket) Emit(event string, args ...interface{}) error    { return nil }
+func (s *quietSocket) Join(room string) error                          { return nil }
+func (s *quietSocket) Leave(room string) error                         { return nil }
+func (s *quietSocket) Disconnect()                                     {}
+func (s *quietSocket) BroadcastTo(r, e string, a ...interface{}) error { return nil }
+
+func TestStalePresenceChangesAreIgnored(t *testing.T) {
+	sh := socket.CreateHandler()
+	gl := CreateGameList(sh, game.DefaultConfig())
+	defer gl.HaltAll()
+	bc, wc := createTestCards()
+	gl.CreateGame(user.User{ID: 1}, "Test", 10, game.Settings{}, bc, wc)
+	u := user.User{ID: 2}
+	gl.JoinGame(u, "Test")
+	presence := func() string {
+		for _, p := range gl.GetStateForUser(user.User{ID: 1}).Players {
+			if p.User.ID == u.ID {
+				return p.Presence
+			}
+		}
+		return ""
+	}
+
+	var first, second socketio.Socket = &quietSocket{id: "first"}, &quietSocket{id: "second"}
+	firstSeq := sh.Add(u.ID, &first)
+	gl.Connect(u, firstSeq)
+	// The user reconnects before the first socket's disconnection is handled
+	sh.Remove(&first)
+	secondSeq := sh.Add(u.ID, &second)
+	gl.Connect(u, secondSeq)
+	gl.Disconnect(u, firstSeq)
+	if p := presence(); p != game.PresenceConnected {
+		t.Errorf("Failed: Expected a late disconnection of an old socket to be ignored, presence is %s", p)
+	}
+
+	sh.Remove(&second)
+	gl.Disconnect(u, secondSeq)
+	gl.Connect(u, firstSeq)
+	if p := presence(); p != game.PresenceDisconnected {
+		t.Errorf("Failed: Expected a stale connection to be ignored after disconnecting, presence is %s", p)
+	}
+}
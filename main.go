@@ -5,20 +5,21 @@ import (
 	"fmt"
 	"os"
 
+	"./config"
 	"./server"
+	"./user"
 
 	_ "github.com/lib/pq"
 )
 
-const (
-	DB_USER     = "student"
-	DB_PASSWORD = "student"
-	DB_NAME     = "cards"
-)
-
 func main() {
-	dbinfo := fmt.Sprintf("user=%s password=%s dbname=%s sslmode=disable", DB_USER, DB_PASSWORD, DB_NAME)
-	db, err := sql.Open("postgres", dbinfo)
+	cfg, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Println("Error loading configuration:", err)
+		os.Exit(10)
+	}
+
+	db, err := sql.Open("postgres", cfg.DatabaseDSN)
 	if err != nil {
 		fmt.Println("Error connecting to database:", err)
 		os.Exit(11)
@@ -26,5 +27,14 @@ func main() {
 	defer db.Close()
 	fmt.Println("Successfully connected to database!")
 
-	server.StartHTTP(db)
+	auth, err := user.CreateAuthenticator(cfg.AuthMode, cfg.AuthSecret, cfg.SessionUserKeyPaths, db)
+	if err != nil {
+		fmt.Println("Error configuring authentication:", err)
+		os.Exit(12)
+	}
+
+	if err := server.StartHTTP(cfg, db, auth); err != nil {
+		fmt.Println("Error running server:", err)
+		os.Exit(13)
+	}
 }
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+
+	"../apperror"
+	"../card"
+	"../gamelist"
+	"../user"
+	"./socket"
+)
+
+// Inbound socket action types
+const (
+	actionCreateGame = "game/CREATE"
+	actionStartGame  = "game/START"
+	actionStopGame   = "game/STOP"
+	actionJoinGame   = "game/JOIN"
+	actionLeaveGame  = "game/LEAVE"
+	actionPlayCard   = "game/PLAY_CARD"
+	actionVoteCard   = "game/VOTE"
+	actionKickPlayer = "game/KICK_PLAYER"
+	actionSetAway    = "game/SET_AWAY"
+	actionResume     = "game/RESUME_PLAYING"
+	actionRebootHand = "game/REBOOT_HAND"
+	actionEndGame    = "game/HAPPY_ENDING"
+	actionDiscard    = "game/DISCARD_CARD"
+)
+
+// handleAction performs a game command sent over a socket by a user
+func handleAction(u user.User, a socket.InboundAction, cards card.Store, gl *gamelist.GameList) error {
+	switch a.Type {
+	case actionCreateGame:
+		var msg GameCreateMessage
+		if err := decodePayload(a, &msg); err != nil {
+			return err
+		}
+		bc, wc, err := cards.GetCards(msg.CardpackIDs)
+		if err != nil {
+			return err
+		}
+		return gl.CreateGame(u, msg.Name, msg.MaxPlayers, msg.Settings, bc, wc)
+	case actionStartGame:
+		return gl.StartGame(u.ID)
+	case actionStopGame:
+		return gl.StopGame(u.ID)
+	case actionJoinGame:
+		var name string
+		if err := decodePayload(a, &name); err != nil {
+			return err
+		}
+		return gl.JoinGame(u, name)
+	case actionLeaveGame:
+		gl.LeaveGame(u)
+		return nil
+	case actionPlayCard:
+		var cardID int
+		if err := decodePayload(a, &cardID); err != nil {
+			return err
+		}
+		return gl.PlayCard(u, cardID)
+	case actionVoteCard:
+		var cardID int
+		if err := decodePayload(a, &cardID); err != nil {
+			return err
+		}
+		return gl.VoteCard(u, cardID)
+	case actionKickPlayer:
+		var userID int
+		if err := decodePayload(a, &userID); err != nil {
+			return err
+		}
+		return gl.KickUser(u, userID)
+	case actionSetAway:
+		var away bool
+		if err := decodePayload(a, &away); err != nil {
+			return err
+		}
+		return gl.SetAway(u, away)
+	case actionResume:
+		return gl.ResumePlaying(u)
+	case actionRebootHand:
+		return gl.RebootHand(u)
+	case actionEndGame:
+		return gl.DeclareHappyEnding(u)
+	case actionDiscard:
+		var cardID int
+		if err := decodePayload(a, &cardID); err != nil {
+			return err
+		}
+		return gl.DiscardCard(u, cardID)
+	}
+	return apperror.Validation("Unknown action type " + a.Type)
+}
+
+// decodePayload unmarshals an action's payload, rejecting missing or malformed payloads
+func decodePayload(a socket.InboundAction, v interface{}) error {
+	if len(a.Payload) == 0 || string(a.Payload) == "null" {
+		return apperror.Validation("Missing payload for " + a.Type)
+	}
+	if err := json.Unmarshal(a.Payload, v); err != nil {
+		return apperror.Validation("Invalid payload for " + a.Type)
+	}
+	return nil
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googollee/go-socket.io"
+
+	"../apperror"
+	"../card"
+	"../gamelist"
+	"../gamelist/game"
+	"../user"
+	"./socket"
+)
+
+// replySocket records the acknowledgements and errors sent back to it
+type replySocket struct {
+	mu      sync.Mutex
+	replies []socket.Action
+}
+
+func (s *replySocket) Id() string                                      { return "reply" }
+func (s *replySocket) Rooms() []string                                 { return nil }
+func (s *replySocket) Request() *http.Request                          { return nil }
+func (s *replySocket) On(event string, f interface{}) error            { return nil }
+func (s *replySocket) Join(room string) error                          { return nil }
+func (s *replySocket) Leave(room string) error                         { return nil }
+func (s *replySocket) Disconnect()                                     {}
+func (s *replySocket) BroadcastTo(r, e string, a ...interface{}) error { return nil }
+func (s *replySocket) Emit(event string, args ...interface{}) error {
+	a := args[0].(socket.Action)
+	if a.Type == "game/ACTION_ACK" || a.Type == "game/ACTION_ERROR" {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.replies = append(s.replies, a)
+	}
+	return nil
+}
+
+// waitForReply waits for the reply to the nth action sent by the socket
+func (s *replySocket) waitForReply(t *testing.T, n int) socket.Action {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		s.mu.Lock()
+		if len(s.replies) >= n {
+			defer s.mu.Unlock()
+			return s.replies[n-1]
+		}
+		s.mu.Unlock()
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("Failed: Expected a reply to action %d", n)
+	return socket.Action{}
+}
+
+func createTestStore() card.Store {
+	cards := []card.Card{}
+	for i := 1; i <= 20; i++ {
+		cards = append(cards, card.Card{ID: i, Type: "black", Text: "Black", AnswerFields: 1, CardpackID: 1})
+	}
+	for i := 21; i <= 120; i++ {
+		cards = append(cards, card.Card{ID: i, Type: "white", Text: "White", CardpackID: 1})
+	}
+	return card.CreateMemoryStore([]card.Cardpack{{ID: 1, Name: "Test"}}, cards)
+}
+
+func TestHandleAction(t *testing.T) {
+	sh := socket.CreateHandler()
+	gl := gamelist.CreateGameList(sh, game.DefaultConfig())
+	defer gl.HaltAll()
+	cards := createTestStore()
+	sockets := map[int]*replySocket{}
+	for id := 1; id <= 3; id++ {
+		s := &replySocket{}
+		var so socketio.Socket = s
+		sh.Add(id, &so)
+		sockets[id] = s
+	}
+
+	tests := []struct {
+		userID  int
+		action  string
+		payload string
+		err     error // The kind of error expected, nil when the action should be acknowledged
+	}{
+		{1, "game/UNKNOWN", "", apperror.ErrValidation},
+		{1, actionCreateGame, "", apperror.ErrValidation},
+		{1, actionCreateGame, `"Test"`, apperror.ErrValidation},
+		{1, actionCreateGame, `{"name":"Test","cardpackIDs":[2],"maxPlayers":3}`, apperror.ErrValidation},
+		{1, actionCreateGame, `{"name":"Test","cardpackIDs":[1],"maxPlayers":3,"houseRules":["free parking"]}`, apperror.ErrValidation},
+		{1, actionCreateGame, `{"name":"Test","cardpackIDs":[1],"maxPlayers":3}`, nil},
+		{2, actionJoinGame, "null", apperror.ErrValidation},
+		{2, actionJoinGame, "5", apperror.ErrValidation},
+		{2, actionJoinGame, `"Missing"`, apperror.ErrNotFound},
+		{2, actionJoinGame, `"Test"`, nil},
+		{3, actionJoinGame, `"Test"`, nil},
+		{2, actionStartGame, "", apperror.ErrForbidden},
+		{1, actionStartGame, "", nil},
+		{2, actionPlayCard, `"abc"`, apperror.ErrValidation},
+		{2, actionPlayCard, "1", apperror.ErrValidation},
+		{2, actionVoteCard, "", apperror.ErrValidation},
+		{2, actionVoteCard, "21", apperror.ErrInvalidState},
+		{1, actionKickPlayer, `"x"`, apperror.ErrValidation},
+		{1, actionKickPlayer, "1", apperror.ErrValidation},
+		{2, actionSetAway, `"yes"`, apperror.ErrValidation},
+		{2, actionSetAway, "true", nil},
+		{2, actionResume, "", apperror.ErrInvalidState},
+		{2, actionRebootHand, "", apperror.ErrInvalidState},
+		{1, actionEndGame, "", apperror.ErrInvalidState},
+		{2, actionDiscard, "", apperror.ErrValidation},
+		{2, actionDiscard, "21", apperror.ErrInvalidState},
+		{1, actionStopGame, "", nil},
+		{3, actionLeaveGame, "", nil},
+		{3, actionStopGame, "", apperror.ErrNotFound},
+	}
+	for _, test := range tests {
+		u := user.User{ID: test.userID}
+		a := socket.InboundAction{Type: test.action, Payload: json.RawMessage(test.payload)}
+		if err := handleAction(u, a, cards, gl); !errors.Is(err, test.err) {
+			t.Errorf("Failed: Expected %s %s from user %d to fail with %v, got %v", test.action, test.payload, test.userID, test.err, err)
+		}
+	}
+
+	// Replies are checked against a fresh game so every action above is repeated from the same state
+	gl.HaltAll()
+	gl = gamelist.CreateGameList(sh, game.DefaultConfig())
+	sent := map[int]int{}
+	for _, test := range tests {
+		u := user.User{ID: test.userID}
+		s := sockets[test.userID]
+		var so socketio.Socket = s
+		sent[test.userID]++
+		respondToAction(&so, u, socket.InboundAction{Type: test.action, Payload: json.RawMessage(test.payload)}, cards, sh, gl)
+		reply := s.waitForReply(t, sent[test.userID])
+		if test.err == nil {
+			if reply.Type != "game/ACTION_ACK" || reply.Payload != test.action {
+				t.Errorf("Failed: Expected %s from user %d to be acknowledged, got %+v", test.action, test.userID, reply)
+			}
+		} else if msg, ok := reply.Payload.(socket.ActionError); reply.Type != "game/ACTION_ERROR" || !ok || msg.Type != test.action || msg.Message == "" {
+			t.Errorf("Failed: Expected %s from user %d to be answered with an error, got %+v", test.action, test.userID, reply)
+		}
+	}
+}
+
+func TestHouseRuleActions(t *testing.T) {
+	sh := socket.CreateHandler()
+	gl := gamelist.CreateGameList(sh, game.DefaultConfig())
+	defer gl.HaltAll()
+	cards := createTestStore()
+	create := `{"name":"Test","cardpackIDs":[1],"maxPlayers":3,"houseRules":["rebootingTheUniverse","happyEnding","neverHaveIEver"]}`
+	steps := []struct {
+		userID  int
+		action  string
+		payload string
+	}{
+		{1, actionCreateGame, create},
+		{2, actionJoinGame, `"Test"`},
+		{3, actionJoinGame, `"Test"`},
+		{1, actionStartGame, ""},
+	}
+	for _, step := range steps {
+		a := socket.InboundAction{Type: step.action, Payload: json.RawMessage(step.payload)}
+		if err := handleAction(user.User{ID: step.userID}, a, cards, gl); err != nil {
+			t.Fatalf("Failed: Could not %s - %v", step.action, err)
+		}
+	}
+
+	u := user.User{ID: 2}
+	if err := handleAction(u, socket.InboundAction{Type: actionRebootHand}, cards, gl); !errors.Is(err, apperror.ErrInvalidState) {
+		t.Errorf("Failed: Expected rebooting without a point to be rejected, got %v", err)
+	}
+	if err := handleAction(u, socket.InboundAction{Type: actionEndGame}, cards, gl); !errors.Is(err, apperror.ErrForbidden) {
+		t.Errorf("Failed: Expected only the owner to call the final round, got %v", err)
+	}
+	if err := handleAction(user.User{ID: 1}, socket.InboundAction{Type: actionEndGame}, cards, gl); err != nil {
+		t.Errorf("Failed: Expected the owner to call the final round - %v", err)
+	}
+	c := gl.GetStateForUser(u).Hand[0]
+	a := socket.InboundAction{Type: actionDiscard, Payload: json.RawMessage(strconv.Itoa(c.ID))}
+	if err := handleAction(u, a, cards, gl); err != nil {
+		t.Fatalf("Failed: Could not discard - %v", err)
+	}
+	for _, h := range gl.GetStateForUser(u).Hand {
+		if h.ID == c.ID {
+			t.Errorf("Failed: Expected card %d to be discarded", c.ID)
+		}
+	}
+	if err := handleAction(u, a, cards, gl); !errors.Is(err, apperror.ErrValidation) {
+		t.Errorf("Failed: Expected a discarded card to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"../apperror"
+)
+
+// ErrorMessage JSON structure for failed HTTP requests
+type ErrorMessage struct {
+	Error string `json:"error"`
+}
+
+// errorStatus maps an error to the HTTP status code that describes it
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, apperror.ErrUnauthorized):
+		return http.StatusUnauthorized
+	case errors.Is(err, apperror.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, apperror.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, apperror.ErrInvalidState):
+		return http.StatusConflict
+	case errors.Is(err, apperror.ErrValidation):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
+
+// writeError responds to an HTTP request with an error's status code and a JSON error body
+func writeError(w http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(errorStatus(err))
+	json.NewEncoder(w).Encode(ErrorMessage{Error: err.Error()})
+}
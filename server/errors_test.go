@@ -0,0 +1,35 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"../apperror"
+)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		err    error
+		status int
+	}{
+		{apperror.Unauthorized("Cookie is not valid"), 401},
+		{apperror.Forbidden("Only the judge can vote"), 403},
+		{apperror.NotFound("Game does not exist"), 404},
+		{apperror.InvalidState("Game is full"), 409},
+		{apperror.Validation("Card is not in your hand"), 422},
+		{errors.New("Connection refused"), 500},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		writeError(w, test.err)
+		if w.Code != test.status {
+			t.Errorf("Failed: Expected %q to have status %d, got %d", test.err, test.status, w.Code)
+		}
+		var msg ErrorMessage
+		if err := json.NewDecoder(w.Body).Decode(&msg); err != nil || msg.Error != test.err.Error() {
+			t.Errorf("Failed: Expected JSON error body %q, got %q (%v)", test.err, msg.Error, err)
+		}
+	}
+}
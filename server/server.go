@@ -5,58 +5,109 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/googollee/go-socket.io"
 	"github.com/rs/cors"
 
+	"../apperror"
 	"../card"
+	"../config"
 	"../gamelist"
+	"../gamelist/game"
+	"../snapshot"
 	"../user"
 	"./socket"
 )
 
-// StartHTTP begins the socket server
-func StartHTTP(db *sql.DB) {
+// StartHTTP begins the socket server and runs it until the process is told to stop
+func StartHTTP(cfg config.Config, db *sql.DB, auth user.Authenticator) error {
 	c := cors.New(cors.Options{
-		AllowedOrigins:   []string{"http://localhost:3000"},
+		AllowedOrigins:   cfg.AllowedOrigins,
 		AllowCredentials: true,
 	})
 	sh := socket.CreateHandler()
-	games := gamelist.CreateGameList(sh)
+	games := gamelist.CreateGameList(sh, cfg.GameConfig())
 
 	socketIOMux, err := socketio.NewServer(nil)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	cards := card.CreatePostgresStore(db)
+
+	store, err := snapshot.CreateStore(cfg.SnapshotStore, cfg.SnapshotDir, db)
+	if err != nil {
+		return err
+	}
+	var snaps *snapshotter
+	stopSnapshots := make(chan struct{})
+	if store != nil {
+		snaps = &snapshotter{store: store, games: games}
+		if err := snaps.restore(); err != nil {
+			return err
+		}
+		go snaps.run(time.Duration(cfg.SnapshotInterval)*time.Second, stopSnapshots)
 	}
 
 	socketIOMux.On("connection", func(s socketio.Socket) {
-		go initSocket(&s, db, sh, &games)
+		go initSocket(&s, auth, cards, sh, games)
 	})
-	http.Handle("/socket.io/", c.Handler(socketIOMux))
-	http.Handle("/game/", c.Handler(createGameMux("/game", db, sh, &games)))
-	http.Handle("/gamelist", c.Handler(createGameListMux("/gamelist", db, sh, &games)))
+	mux := http.NewServeMux()
+	mux.Handle("/socket.io/", c.Handler(socketIOMux))
+	mux.Handle("/game/", c.Handler(createGameMux("/game", auth, cards, sh, games)))
+	mux.Handle("/gamelist", c.Handler(createGameListMux("/gamelist", db, sh, games)))
+	srv := &http.Server{Addr: cfg.ListenAddress, Handler: mux}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, os.Interrupt)
+	errs := make(chan error, 1)
 	fmt.Println("Starting HTTP/Socket server...")
-	http.ListenAndServe(":8000", nil)
-}
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
 
-func initSocket(so *socketio.Socket, db *sql.DB, sh *socket.Handler, games *gamelist.GameList) {
-	cookie, err := (*so).Request().Cookie("connect.sid")
-	if err != nil {
-		return
+	select {
+	case err := <-errs:
+		return err
+	case <-signals:
 	}
-	u, err := user.GetByCookie(cookie.Value, db)
+	close(stopSnapshots)
+	return shutdown(srv, cfg, sh, games, snaps, signals)
+}
+
+func initSocket(so *socketio.Socket, auth user.Authenticator, cards card.Store, sh *socket.Handler, games *gamelist.GameList) {
+	u, err := auth.Authenticate((*so).Request())
 	if err != nil {
 		return
 	}
 	fmt.Println("A user has connected")
-	sh.Add(u.ID, so)
+	seq := sh.Add(u.ID, so)
+	games.Connect(u, seq)
 	(*so).On("disconnection", func() {
 		fmt.Println("A user has disconnected")
 		sh.Remove(so)
+		games.Disconnect(u, seq)
 	})
-	sh.SendActionToUser(u.ID, socket.Action{Type: "game/SET_GAME_STATE", Payload: games.GetStateForUser(u)})
+	(*so).On("action", func(a socket.InboundAction) {
+		respondToAction(so, u, a, cards, sh, games)
+	})
+	sh.SendActionToUser(u.ID, socket.Action{Type: socket.StateAction, Payload: games.GetStateForUser(u)})
+}
+
+// respondToAction performs an action sent over a socket, replying with an acknowledgement and the new
+// game state or with the reason it failed
+func respondToAction(so *socketio.Socket, u user.User, a socket.InboundAction, cards card.Store, sh *socket.Handler, games *gamelist.GameList) {
+	if err := handleAction(u, a, cards, games); err != nil {
+		sh.SendActionToSocket(so, socket.Action{Type: "game/ACTION_ERROR", Payload: socket.ActionError{Type: a.Type, Message: err.Error()}})
+		return
+	}
+	sh.SendActionToSocket(so, socket.Action{Type: "game/ACTION_ACK", Payload: a.Type})
+	sh.SendActionToUser(u.ID, socket.Action{Type: socket.StateAction, Payload: games.GetStateForUser(u)})
 }
 
 // GameCreateMessage JSON structure for HTTP requests to the game creation endpoint
@@ -64,97 +115,106 @@ type GameCreateMessage struct {
 	Name        string `json:"name"`
 	CardpackIDs []int  `json:"cardpackIDs"`
 	MaxPlayers  int    `json:"maxPlayers"`
+	game.Settings
 }
 
-func createGameMux(path string, db *sql.DB, sh *socket.Handler, gl *gamelist.GameList) http.Handler {
+func createGameMux(path string, auth user.Authenticator, cards card.Store, sh *socket.Handler, gl *gamelist.GameList) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc(path+"/state", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 		json.NewEncoder(w).Encode(gl.GetStateForUser(u))
 	})
 	mux.HandleFunc(path+"/create", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 		var msg GameCreateMessage
 		err = json.Unmarshal(b, &msg)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, apperror.Validation("Request body is not valid JSON"))
 			return
 		}
 
-		bc, wc := card.GetCards(msg.CardpackIDs, db)
-		err = gl.CreateGame(u, msg.Name, msg.MaxPlayers, bc, wc)
+		bc, wc, err := cards.GetCards(msg.CardpackIDs)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		err = gl.CreateGame(u, msg.Name, msg.MaxPlayers, msg.Settings, bc, wc)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 		json.NewEncoder(w).Encode(gl.GetStateForUser(u))
 	})
 	mux.HandleFunc(path+"/start", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		err = gl.StartGame(u.ID)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		json.NewEncoder(w).Encode(gl.GetStateForUser(u))
 	})
 	mux.HandleFunc(path+"/stop", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		err = gl.StopGame(u.ID)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		json.NewEncoder(w).Encode(gl.GetStateForUser(u))
 	})
 	mux.HandleFunc(path+"/join", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
-		gl.JoinGame(u, string(b))
+		err = gl.JoinGame(u, string(b))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		json.NewEncoder(w).Encode(gl.GetStateForUser(u))
 	})
 	mux.HandleFunc(path+"/leave", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
@@ -162,72 +222,84 @@ func createGameMux(path string, db *sql.DB, sh *socket.Handler, gl *gamelist.Gam
 		json.NewEncoder(w).Encode(gl.GetStateForUser(u))
 	})
 	mux.HandleFunc(path+"/card", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 		var msg int
 		err = json.Unmarshal(b, &msg)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, apperror.Validation("Request body is not valid JSON"))
 			return
 		}
 
-		gl.PlayCard(u, msg)
+		err = gl.PlayCard(u, msg)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		json.NewEncoder(w).Encode(true)
 	})
 	mux.HandleFunc(path+"/kickplayer", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 		var msg int
 		err = json.Unmarshal(b, &msg)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, apperror.Validation("Request body is not valid JSON"))
 			return
 		}
 
-		gl.KickUser(u, msg)
+		err = gl.KickUser(u, msg)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		json.NewEncoder(w).Encode(true)
 	})
 	mux.HandleFunc(path+"/vote", func(w http.ResponseWriter, r *http.Request) {
-		u, err := user.GetByRequest(r, db)
+		u, err := auth.Authenticate(r)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 
 		b, err := ioutil.ReadAll(r.Body)
 		defer r.Body.Close()
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, err)
 			return
 		}
 		var msg int
 		err = json.Unmarshal(b, &msg)
 		if err != nil {
-			http.Error(w, err.Error(), 500)
+			writeError(w, apperror.Validation("Request body is not valid JSON"))
 			return
 		}
 
-		gl.VoteCard(u, msg)
+		err = gl.VoteCard(u, msg)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
 		json.NewEncoder(w).Encode(true)
 	})
 	return mux
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"../config"
+	"../gamelist"
+	"./socket"
+)
+
+// ShutdownMessage JSON structure for the server/SHUTTING_DOWN action
+type ShutdownMessage struct {
+	Deadline time.Time `json:"deadline"`
+}
+
+// shutdown stops new games, lets rounds in progress finish until the drain timeout passes (or another
+// signal arrives), then halts and snapshots all games and stops the HTTP server. Games are frozen between
+// rounds rather than played to the end, since a game without a win condition never ends.
+func shutdown(srv *http.Server, cfg config.Config, sh *socket.Handler, games *gamelist.GameList, snaps *snapshotter, signals chan os.Signal) error {
+	fmt.Println("Shutting down...")
+	games.Drain()
+	deadline := time.Now().Add(time.Duration(cfg.DrainTimeout) * time.Second)
+	sh.SendActionToAllUsers(socket.Action{Type: "server/SHUTTING_DOWN", Payload: ShutdownMessage{Deadline: deadline}})
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for games.HaltBetweenRounds() > 0 && time.Now().Before(deadline) {
+		select {
+		case <-ticker.C:
+		case <-signals:
+			fmt.Println("Skipping the wait for rounds in progress")
+			deadline = time.Now()
+		}
+	}
+	games.HaltAll()
+	if snaps != nil {
+		if err := snaps.save(); err != nil {
+			fmt.Println("Error saving game snapshots:", err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+	defer cancel()
+	return srv.Shutdown(ctx)
+}
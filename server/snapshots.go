@@ -0,0 +1,52 @@
+package server
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"../gamelist"
+	"../snapshot"
+)
+
+// snapshotter periodically saves every game to a snapshot store, one save at a time
+type snapshotter struct {
+	mu    sync.Mutex
+	store snapshot.Store
+	games *gamelist.GameList
+}
+
+// restore loads saved games into the game list, skipping any that cannot be restored
+func (s *snapshotter) restore() error {
+	snapshots, err := s.store.Load()
+	if err != nil {
+		return err
+	}
+	if err := s.games.Restore(snapshots); err != nil {
+		fmt.Println("Error restoring games:", err)
+	}
+	fmt.Printf("Restored games from %d snapshots\n", len(snapshots))
+	return nil
+}
+
+// run saves a snapshot every interval until stop is closed
+func (s *snapshotter) run(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.save(); err != nil {
+				fmt.Println("Error saving game snapshots:", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (s *snapshotter) save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.store.Save(s.games.Snapshot())
+}
@@ -2,12 +2,34 @@ package socket
 
 import "github.com/googollee/go-socket.io"
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+)
 
-// Handler manages user sockets
+// queueSize is the number of actions that can be waiting to be sent to a single socket
+const queueSize = 64
+
+// StateAction is the type of action carrying a user's full game state, only the latest one is worth sending
+const StateAction = "game/SET_GAME_STATE"
+
+// Handler manages user sockets, safe for concurrent use
 type Handler struct {
-	uToS map[int][]socketio.Socket
-	sToU map[socketio.Socket]int
+	mu     sync.RWMutex
+	uToS   map[int][]socketio.Socket
+	sToU   map[socketio.Socket]int
+	queues map[socketio.Socket]*outbox
+	seq    int // Incremented for every socket added so that connections can be ordered
+}
+
+// outbox - The actions waiting to be sent to a single socket
+type outbox struct {
+	mu      sync.Mutex
+	actions []Action
+	closed  bool          // Set once the socket has fallen too far behind, after which actions are dropped
+	ready   chan struct{} // Signalled when actions are added, closed when the socket is removed
 }
 
 // Action - A Redux-Socket.IO action
@@ -16,23 +38,49 @@ type Action struct {
 	Payload interface{} `json:"payload"`
 }
 
+// InboundAction - A Redux-Socket.IO action sent by a client, with its payload left undecoded
+type InboundAction struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// ActionError - The payload sent back to a socket when one of its actions fails
+type ActionError struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
 // CreateHandler generates a socket handler
 func CreateHandler() *Handler {
-	return &Handler{uToS: make(map[int][]socketio.Socket), sToU: make(map[socketio.Socket]int)}
+	return &Handler{
+		uToS:   make(map[int][]socketio.Socket),
+		sToU:   make(map[socketio.Socket]int),
+		queues: make(map[socketio.Socket]*outbox),
+	}
 }
 
-// Add registers reference to a socket
-func (h Handler) Add(userID int, s *socketio.Socket) {
-	if _, ok := h.uToS[userID]; ok {
-		h.uToS[userID] = append(h.uToS[userID], *s)
-	} else {
-		h.uToS[userID] = []socketio.Socket{*s}
+// Add registers reference to a socket, returning a sequence number that is higher for every socket added
+// (or 0 if the socket was already registered)
+func (h *Handler) Add(userID int, s *socketio.Socket) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.sToU[*s]; ok {
+		return 0
 	}
+	h.uToS[userID] = append(h.uToS[userID], *s)
 	h.sToU[*s] = userID
+
+	q := &outbox{ready: make(chan struct{}, 1)}
+	h.queues[*s] = q
+	go emitActions(*s, q)
+	h.seq++
+	return h.seq
 }
 
 // Remove deletes reference to a socket
-func (h Handler) Remove(s *socketio.Socket) {
+func (h *Handler) Remove(s *socketio.Socket) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
 	if userID, ok := h.sToU[*s]; ok {
 		for i, soc := range h.uToS[userID] {
 			if *s == soc {
@@ -44,30 +92,95 @@ func (h Handler) Remove(s *socketio.Socket) {
 			delete(h.uToS, userID)
 		}
 		delete(h.sToU, *s)
+		close(h.queues[*s].ready)
+		delete(h.queues, *s)
 	} else {
 		fmt.Println("Attempted to delete a socket that does not exist")
 	}
 }
 
+// IsConnected returns whether a user has any sockets open
+func (h *Handler) IsConnected(userID int) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.uToS[userID]) > 0
+}
+
 // SendActionToUser sends data to all sockets belonging to a particular user
-func (h Handler) SendActionToUser(userID int, action Action) {
-	if _, ok := h.uToS[userID]; ok {
-		for _, s := range h.uToS[userID] {
-			s.Emit("action", action)
-		}
+func (h *Handler) SendActionToUser(userID int, action Action) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, s := range h.uToS[userID] {
+		h.enqueue(s, action)
 	}
 }
 
 // SendActionToUsers sends data to all sockets belonging to a list of users
-func (h Handler) SendActionToUsers(userIDs []int, action Action) {
+func (h *Handler) SendActionToUsers(userIDs []int, action Action) {
 	for _, id := range userIDs {
 		h.SendActionToUser(id, action)
 	}
 }
 
 // SendActionToAllUsers sends data to all sockets
-func (h Handler) SendActionToAllUsers(action Action) {
+func (h *Handler) SendActionToAllUsers(action Action) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 	for s := range h.sToU {
-		s.Emit("action", action)
+		h.enqueue(s, action)
+	}
+}
+
+// SendActionToSocket sends data to a single socket
+func (h *Handler) SendActionToSocket(s *socketio.Socket, action Action) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if _, ok := h.queues[*s]; ok {
+		h.enqueue(*s, action)
+	}
+}
+
+// enqueue queues an action for a socket without waiting. A new state replaces any state still waiting to be sent,
+// and a socket that falls too far behind anyway is disconnected so it resyncs when it reconnects.
+func (h *Handler) enqueue(s socketio.Socket, action Action) {
+	q := h.queues[s]
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return
+	}
+	if action.Type == StateAction {
+		for i, a := range q.actions {
+			if a.Type == StateAction {
+				q.actions = append(q.actions[:i], q.actions[i+1:]...)
+				break
+			}
+		}
+	}
+	if len(q.actions) >= queueSize {
+		log.Printf("Disconnecting socket %s after it fell %d actions behind", s.Id(), queueSize)
+		q.closed = true
+		q.actions = nil
+		// Disconnecting removes the socket, which needs the handler lock held by our caller
+		go s.Disconnect()
+		return
+	}
+	q.actions = append(q.actions, action)
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// emitActions sends queued actions to a socket until it is removed
+func emitActions(s socketio.Socket, q *outbox) {
+	for range q.ready {
+		q.mu.Lock()
+		actions := q.actions
+		q.actions = nil
+		q.mu.Unlock()
+		for _, action := range actions {
+			s.Emit("action", action)
+		}
 	}
 }
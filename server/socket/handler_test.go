@@ -0,0 +1,147 @@
+package socket
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googollee/go-socket.io"
+)
+
+// fakeSocket records emitted actions, optionally blocking until released
+type fakeSocket struct {
+	id           string
+	mu           sync.Mutex
+	emitted      []Action
+	block        chan struct{}
+	disconnected bool
+}
+
+func (s *fakeSocket) Id() string             { return s.id }
+func (s *fakeSocket) Rooms() []string        { return nil }
+func (s *fakeSocket) Request() *http.Request { return nil }
+func (s *fakeSocket) On(event string, f interface{}) error {
+	return nil
+}
+func (s *fakeSocket) Emit(event string, args ...interface{}) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.emitted = append(s.emitted, args[0].(Action))
+	return nil
+}
+func (s *fakeSocket) Join(room string) error  { return nil }
+func (s *fakeSocket) Leave(room string) error { return nil }
+func (s *fakeSocket) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.disconnected = true
+}
+func (s *fakeSocket) BroadcastTo(room, event string, args ...interface{}) error {
+	return nil
+}
+
+func (s *fakeSocket) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.emitted)
+}
+
+func (s *fakeSocket) isDisconnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.disconnected
+}
+
+func waitForCount(t *testing.T, s *fakeSocket, n int) {
+	deadline := time.Now().Add(time.Second)
+	for s.count() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("Failed: Expected socket %s to receive %d actions, received %d", s.id, n, s.count())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestSlowSocketDoesNotBlockOthers(t *testing.T) {
+	h := CreateHandler()
+	slow := &fakeSocket{id: "slow", block: make(chan struct{})}
+	fast := &fakeSocket{id: "fast"}
+	var slowSocket, fastSocket socketio.Socket = slow, fast
+	h.Add(1, &slowSocket)
+	h.Add(2, &fastSocket)
+
+	// Overflowing the slow socket's queue must not block the sender
+	for i := 0; i < queueSize*2; i++ {
+		h.SendActionToUser(1, Action{Type: "test"})
+	}
+	for i := 0; i < queueSize; i++ {
+		h.SendActionToAllUsers(Action{Type: "test"})
+	}
+	waitForCount(t, fast, queueSize)
+	deadline := time.Now().Add(time.Second)
+	for !slow.isDisconnected() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Failed: Expected the slow socket to be disconnected once its queue overflowed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(slow.block)
+	h.Remove(&slowSocket)
+	h.Remove(&fastSocket)
+}
+
+func TestConcurrentAddRemoveSend(t *testing.T) {
+	h := CreateHandler()
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(userID int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				var s socketio.Socket = &fakeSocket{id: "socket"}
+				h.Add(userID, &s)
+				h.SendActionToUser(userID, Action{Type: "test"})
+				h.SendActionToUsers([]int{userID, userID + 1}, Action{Type: "test"})
+				h.SendActionToAllUsers(Action{Type: "test"})
+				h.Remove(&s)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if len(h.sToU) != 0 || len(h.uToS) != 0 || len(h.queues) != 0 {
+		t.Errorf("Failed: Expected all sockets to be removed")
+	}
+}
+
+func TestQueuedStatesAreCoalesced(t *testing.T) {
+	h := CreateHandler()
+	slow := &fakeSocket{id: "slow", block: make(chan struct{})}
+	var slowSocket socketio.Socket = slow
+	h.Add(1, &slowSocket)
+
+	// The first action is taken straight away and blocks the socket while the rest queue up
+	h.SendActionToUser(1, Action{Type: "first"})
+	time.Sleep(10 * time.Millisecond)
+	for i := 0; i < queueSize*2; i++ {
+		h.SendActionToUser(1, Action{Type: StateAction, Payload: i})
+	}
+	h.SendActionToUser(1, Action{Type: "last"})
+	close(slow.block)
+	waitForCount(t, slow, 3)
+	time.Sleep(10 * time.Millisecond)
+
+	slow.mu.Lock()
+	defer slow.mu.Unlock()
+	if slow.disconnected || len(slow.emitted) != 3 {
+		t.Fatalf("Failed: Expected the queued states to be coalesced into one, got %d actions", len(slow.emitted))
+	}
+	if state := slow.emitted[1]; state.Type != StateAction || state.Payload != queueSize*2-1 || slow.emitted[2].Type != "last" {
+		t.Errorf("Failed: Expected only the latest state followed by the last action, got %v", slow.emitted[1:])
+	}
+	h.Remove(&slowSocket)
+}
@@ -0,0 +1,102 @@
+package snapshot
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"../gamelist/game"
+)
+
+// FileStore - A snapshot store that keeps one JSON file per game in a directory
+type FileStore struct {
+	dir string
+}
+
+// CreateFileStore generates a file store, creating its directory if needed
+func CreateFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("A snapshot directory is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes each snapshot to its own file and removes files for games that no longer exist
+func (s *FileStore) Save(snapshots []game.Snapshot) error {
+	keep := make(map[string]bool)
+	for _, snap := range snapshots {
+		b, err := json.Marshal(snap)
+		if err != nil {
+			return err
+		}
+		name := url.PathEscape(snap.Name) + ".json"
+		// Write to a temporary file first so a crash never leaves a half written snapshot
+		tmp := filepath.Join(s.dir, name+".tmp")
+		if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp, filepath.Join(s.dir, name)); err != nil {
+			return err
+		}
+		keep[name] = true
+	}
+
+	files, err := s.files()
+	if err != nil {
+		return err
+	}
+	for _, name := range files {
+		if !keep[name] {
+			if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Load reads every snapshot file in the directory, skipping any that cannot be read
+func (s *FileStore) Load() ([]game.Snapshot, error) {
+	files, err := s.files()
+	if err != nil {
+		return nil, err
+	}
+	snapshots := []game.Snapshot{}
+	for _, name := range files {
+		b, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+		if err != nil {
+			log.Printf("Skipping snapshot %s: %v", name, err)
+			continue
+		}
+		var snap game.Snapshot
+		if err := json.Unmarshal(b, &snap); err != nil {
+			log.Printf("Skipping invalid snapshot %s: %v", name, err)
+			continue
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, nil
+}
+
+// files lists the names of the snapshot files in the directory
+func (s *FileStore) files() ([]string, error) {
+	entries, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
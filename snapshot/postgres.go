@@ -0,0 +1,74 @@
+package snapshot
+
+import (
+	"database/sql"
+	"encoding/json"
+	"log"
+
+	"../gamelist/game"
+)
+
+// PostgresStore - A snapshot store that keeps one row per game in the game_snapshots table
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// CreatePostgresStore generates a Postgres store, creating its table if needed
+func CreatePostgresStore(db *sql.DB) (*PostgresStore, error) {
+	_, err := db.Exec(`CREATE TABLE IF NOT EXISTS game_snapshots (
+		name text PRIMARY KEY,
+		data jsonb NOT NULL,
+		"updatedAt" timestamptz NOT NULL DEFAULT now()
+	)`)
+	if err != nil {
+		return nil, err
+	}
+	return &PostgresStore{db: db}, nil
+}
+
+// Save replaces the contents of the table with the given snapshots in a single transaction
+func (s *PostgresStore) Save(snapshots []game.Snapshot) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM game_snapshots`); err != nil {
+		tx.Rollback()
+		return err
+	}
+	for _, snap := range snapshots {
+		b, err := json.Marshal(snap)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if _, err := tx.Exec(`INSERT INTO game_snapshots (name, data) VALUES ($1, $2)`, snap.Name, string(b)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Load reads every snapshot in the table
+func (s *PostgresStore) Load() ([]game.Snapshot, error) {
+	rows, err := s.db.Query(`SELECT data FROM game_snapshots`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	snapshots := []game.Snapshot{}
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var snap game.Snapshot
+		if err := json.Unmarshal(data, &snap); err != nil {
+			log.Printf("Skipping invalid snapshot: %v", err)
+			continue
+		}
+		snapshots = append(snapshots, snap)
+	}
+	return snapshots, rows.Err()
+}
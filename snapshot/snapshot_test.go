@@ -0,0 +1,99 @@
+package snapshot
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"../card"
+	"../dbtest"
+	"../gamelist/game"
+	"../user"
+)
+
+func createTestSnapshot(name string) game.Snapshot {
+	return game.Snapshot{
+		Name:      name,
+		Players:   []game.PlayerSnapshot{{User: user.User{ID: 1}, Hand: []card.WhiteCard{card.CreateWhiteCard(1, "White", 1)}, Score: 2}},
+		OwnerID:   1,
+		WhiteDraw: []card.WhiteCard{card.CreateWhiteCard(2, "White", 1)},
+		BlackDraw: []card.BlackCard{card.CreateBlackCard(1, "Black", 1, 1)},
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshots")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	s, err := CreateFileStore(dir)
+	if err != nil {
+		t.Fatalf("Failed: Could not create file store - %v", err)
+	}
+
+	if err := s.Save([]game.Snapshot{createTestSnapshot("One"), createTestSnapshot("../Two")}); err != nil {
+		t.Fatalf("Failed: Could not save snapshots - %v", err)
+	}
+	if err := s.Save([]game.Snapshot{createTestSnapshot("../Two")}); err != nil {
+		t.Fatalf("Failed: Could not save snapshots - %v", err)
+	}
+	snapshots, err := s.Load()
+	if err != nil {
+		t.Fatalf("Failed: Could not load snapshots - %v", err)
+	}
+	if len(snapshots) != 1 || snapshots[0].Name != "../Two" {
+		t.Fatalf("Failed: Expected only the latest game to be stored, got %+v", snapshots)
+	}
+	if p := snapshots[0].Players[0]; p.Score != 2 || len(p.Hand) != 1 || p.Hand[0].ID != 1 {
+		t.Errorf("Failed: Expected the player's hand and score to be stored, got %+v", p)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(dir, "Broken.json"), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	snapshots, err = s.Load()
+	if err != nil || len(snapshots) != 1 {
+		t.Errorf("Failed: Expected an invalid snapshot file to be skipped, got %d snapshots (%v)", len(snapshots), err)
+	}
+}
+
+func TestPostgresStore(t *testing.T) {
+	data, _ := json.Marshal(createTestSnapshot("One"))
+	db, rec := dbtest.Open(func(q dbtest.Query) ([]string, [][]driver.Value) {
+		if strings.HasPrefix(q.SQL, "SELECT") {
+			return []string{"data"}, [][]driver.Value{{data}}
+		}
+		return []string{}, nil
+	})
+	defer db.Close()
+	s, err := CreatePostgresStore(db)
+	if err != nil {
+		t.Fatalf("Failed: Could not create Postgres store - %v", err)
+	}
+
+	if err := s.Save([]game.Snapshot{createTestSnapshot("One"), createTestSnapshot("Two")}); err != nil {
+		t.Fatalf("Failed: Could not save snapshots - %v", err)
+	}
+	inserts := 0
+	for _, q := range rec.Queries() {
+		if strings.HasPrefix(q.SQL, "INSERT") {
+			inserts++
+			if len(q.Args) != 2 {
+				t.Errorf("Failed: Expected the name and data to be bound as arguments, got %v", q.Args)
+			}
+		}
+	}
+	if inserts != 2 {
+		t.Errorf("Failed: Expected one insert per game, got %d", inserts)
+	}
+
+	snapshots, err := s.Load()
+	if err != nil || len(snapshots) != 1 || snapshots[0].Name != "One" {
+		t.Errorf("Failed: Expected to load the stored snapshot, got %+v (%v)", snapshots, err)
+	}
+}
@@ -0,0 +1,37 @@
+// Package snapshot saves game snapshots so that games survive a server restart
+package snapshot
+
+import (
+	"database/sql"
+	"errors"
+
+	"../gamelist/game"
+)
+
+// Snapshot store types
+const (
+	StoreNone     = ""
+	StoreFile     = "file"
+	StorePostgres = "postgres"
+)
+
+// Store - Somewhere to keep game snapshots between restarts
+type Store interface {
+	// Save replaces every stored snapshot with the given ones
+	Save(snapshots []game.Snapshot) error
+	// Load returns every stored snapshot
+	Load() ([]game.Snapshot, error)
+}
+
+// CreateStore generates the snapshot store for a store type, returning nil when snapshots are disabled
+func CreateStore(storeType string, dir string, db *sql.DB) (Store, error) {
+	switch storeType {
+	case StoreNone:
+		return nil, nil
+	case StoreFile:
+		return CreateFileStore(dir)
+	case StorePostgres:
+		return CreatePostgresStore(db)
+	}
+	return nil, errors.New("Unknown snapshot store " + storeType)
+}
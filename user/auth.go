@@ -0,0 +1,79 @@
+package user
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"../apperror"
+)
+
+// Authentication modes
+const (
+	AuthSession = "session"
+	AuthToken   = "token"
+	AuthStatic  = "static"
+)
+
+// Authenticator identifies the user that sent an HTTP request or opened a socket
+type Authenticator interface {
+	Authenticate(r *http.Request) (User, error)
+}
+
+// CreateAuthenticator generates the authenticator for a configured mode
+func CreateAuthenticator(mode string, secret string, userKeyPaths []string, db *sql.DB) (Authenticator, error) {
+	switch mode {
+	case AuthSession, "":
+		return CreateSessionAuthenticator(db, secret, userKeyPaths...)
+	case AuthToken:
+		return CreateTokenAuthenticator(secret)
+	case AuthStatic:
+		return CreateStaticAuthenticator(), nil
+	}
+	return nil, errors.New("Unknown authentication mode " + mode)
+}
+
+// StaticAuthenticator trusts the user ID given in the X-User-ID header or userId query parameter.
+// It must only be used for tests and local development.
+type StaticAuthenticator struct {
+	users map[int]User
+}
+
+// CreateStaticAuthenticator generates an authenticator that knows the given users, inventing placeholders for any others
+func CreateStaticAuthenticator(users ...User) *StaticAuthenticator {
+	a := &StaticAuthenticator{users: make(map[int]User)}
+	for _, u := range users {
+		a.users[u.ID] = u
+	}
+	return a
+}
+
+// Authenticate .
+func (a *StaticAuthenticator) Authenticate(r *http.Request) (User, error) {
+	idStr := r.Header.Get("X-User-ID")
+	if idStr == "" {
+		idStr = r.URL.Query().Get("userId")
+	}
+	id, err := strconv.Atoi(idStr)
+	if err != nil || id <= 0 {
+		return User{}, apperror.Unauthorized("Missing user ID")
+	}
+	if u, ok := a.users[id]; ok {
+		return u, nil
+	}
+	return User{ID: id, Name: fmt.Sprint("Player ", id)}, nil
+}
+
+func hmacSHA256(value string, secret string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+func hmacEqual(a string, b string) bool {
+	return hmac.Equal([]byte(a), []byte(b))
+}
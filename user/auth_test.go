@@ -0,0 +1,73 @@
+package user
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenAuthenticator(t *testing.T) {
+	auth, _ := CreateTokenAuthenticator("secret")
+	u := User{ID: 3, Name: "Carol"}
+	token, err := auth.CreateToken(u, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed: Could not create token - %v", err)
+	}
+
+	r := httptest.NewRequest("GET", "/game/state", nil)
+	r.Header.Set("Authorization", "Bearer "+token)
+	if got, err := auth.Authenticate(r); err != nil || got != u {
+		t.Errorf("Failed: Expected header token to identify %v, got %v (%v)", u, got, err)
+	}
+
+	r = httptest.NewRequest("GET", "/socket.io/?token="+token, nil)
+	if got, err := auth.Authenticate(r); err != nil || got != u {
+		t.Errorf("Failed: Expected query token to identify %v, got %v (%v)", u, got, err)
+	}
+
+	other, _ := CreateTokenAuthenticator("other secret")
+	forged, _ := other.CreateToken(User{ID: 1}, time.Minute)
+	expired, _ := auth.CreateToken(u, -time.Minute)
+	// A different user's claims carrying this token's signature
+	tampered := strings.SplitN(forged, ".", 2)[0] + "." + strings.SplitN(token, ".", 2)[1]
+	for _, bad := range []string{"", "garbage", forged, expired, tampered} {
+		r = httptest.NewRequest("GET", "/game/state", nil)
+		r.Header.Set("Authorization", "Bearer "+bad)
+		if _, err := auth.Authenticate(r); err == nil {
+			t.Errorf("Failed: Expected token %q to be rejected", bad)
+		}
+	}
+}
+
+func TestStaticAuthenticator(t *testing.T) {
+	auth := CreateStaticAuthenticator(User{ID: 1, Name: "Alice"})
+
+	r := httptest.NewRequest("GET", "/game/state", nil)
+	r.Header.Set("X-User-ID", "1")
+	if u, err := auth.Authenticate(r); err != nil || u.Name != "Alice" {
+		t.Errorf("Failed: Expected known user Alice, got %v (%v)", u, err)
+	}
+
+	r = httptest.NewRequest("GET", "/socket.io/?userId=2", nil)
+	if u, err := auth.Authenticate(r); err != nil || u.ID != 2 {
+		t.Errorf("Failed: Expected placeholder user 2, got %v (%v)", u, err)
+	}
+
+	r = httptest.NewRequest("GET", "/game/state", nil)
+	if _, err := auth.Authenticate(r); err == nil {
+		t.Errorf("Failed: Expected a request without a user ID to be rejected")
+	}
+}
+
+func TestCreateAuthenticator(t *testing.T) {
+	if _, err := CreateAuthenticator(AuthSession, "", nil, nil); err == nil {
+		t.Errorf("Failed: Expected session mode to require a secret")
+	}
+	if _, err := CreateAuthenticator("magic", "secret", nil, nil); err == nil {
+		t.Errorf("Failed: Expected an unknown mode to be rejected")
+	}
+	if a, err := CreateAuthenticator(AuthToken, "secret", nil, nil); err != nil || a == nil {
+		t.Errorf("Failed: Expected a token authenticator (%v)", err)
+	}
+}
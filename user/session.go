@@ -0,0 +1,152 @@
+package user
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"../apperror"
+)
+
+// SessionCookieName is the cookie set by the Node.js express-session middleware
+const SessionCookieName = "connect.sid"
+
+// DefaultUserKeyPaths are the places in session data where a user ID is looked for, in order
+var DefaultUserKeyPaths = []string{"passport.user", "user"}
+
+// SessionAuthenticator identifies users by their express-session cookie and the "Sessions" table it refers to
+type SessionAuthenticator struct {
+	db           *sql.DB
+	secret       string
+	userKeyPaths []string
+}
+
+// CreateSessionAuthenticator generates an authenticator that verifies session cookies signed with the shared secret.
+// userKeyPaths are dot separated paths to the user ID within session data, defaulting to DefaultUserKeyPaths.
+func CreateSessionAuthenticator(db *sql.DB, secret string, userKeyPaths ...string) (*SessionAuthenticator, error) {
+	if secret == "" {
+		return nil, errors.New("Session authentication requires a session secret")
+	}
+	if len(userKeyPaths) == 0 {
+		userKeyPaths = DefaultUserKeyPaths
+	}
+	return &SessionAuthenticator{db: db, secret: secret, userKeyPaths: userKeyPaths}, nil
+}
+
+// Authenticate .
+func (a *SessionAuthenticator) Authenticate(r *http.Request) (User, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return User{}, apperror.Unauthorized("Missing session cookie")
+	}
+	sid, err := unsignCookie(cookie.Value, a.secret)
+	if err != nil {
+		return User{}, err
+	}
+	uid, err := getIDBySessionID(sid, a.db, a.userKeyPaths)
+	if err != nil {
+		return User{}, err
+	}
+	u, err := GetByID(uid, a.db)
+	if errors.Is(err, apperror.ErrNotFound) {
+		// The session outlived its user, which is a failure to authenticate rather than a missing resource
+		return User{}, apperror.Unauthorized("Session user does not exist")
+	}
+	return u, err
+}
+
+// unsignCookie verifies a cookie-signature style value ("s:<sid>.<signature>") and returns the session ID
+func unsignCookie(value string, secret string) (string, error) {
+	value, err := url.PathUnescape(value)
+	if err != nil || !strings.HasPrefix(value, "s:") {
+		return "", apperror.Unauthorized("Cookie is not valid")
+	}
+	value = value[2:]
+	dot := strings.LastIndex(value, ".")
+	if dot < 0 {
+		return "", apperror.Unauthorized("Cookie is not valid")
+	}
+	sid, signature := value[:dot], value[dot+1:]
+	if !hmacEqual(signature, sign(sid, secret)) {
+		return "", apperror.Unauthorized("Cookie is not valid")
+	}
+	return sid, nil
+}
+
+// sign returns the unpadded base64 HMAC-SHA256 of a value, as produced by the cookie-signature package
+func sign(value string, secret string) string {
+	return base64.RawStdEncoding.EncodeToString(hmacSHA256(value, secret))
+}
+
+func getIDBySessionID(sid string, db *sql.DB, userKeyPaths []string) (int, error) {
+	rows, err := db.Query(`SELECT data FROM "Sessions" WHERE sid = $1`, sid)
+	if err != nil {
+		return -1, err
+	}
+	defer rows.Close()
+	var data string
+	if rows.Next() {
+		if err := rows.Scan(&data); err != nil {
+			return -1, err
+		}
+		return parseUserID(data, userKeyPaths)
+	}
+	return -1, apperror.Unauthorized("Cookie is not valid")
+}
+
+// sessionUserID - A user ID stored in session data as either a JSON number or a numeric string
+type sessionUserID int
+
+func (id *sessionUserID) UnmarshalJSON(b []byte) error {
+	var n json.Number
+	if err := json.Unmarshal(b, &n); err != nil {
+		return apperror.Unauthorized("User ID in session is not a number")
+	}
+	i, err := strconv.Atoi(n.String())
+	if err != nil || i <= 0 {
+		return apperror.Unauthorized("User ID in session is not a valid ID")
+	}
+	*id = sessionUserID(i)
+	return nil
+}
+
+// parseUserID decodes session data and returns the user ID found at the first key path that exists
+func parseUserID(data string, userKeyPaths []string) (int, error) {
+	var session map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(data), &session); err != nil {
+		return -1, apperror.Unauthorized("Session data is not a valid JSON object")
+	}
+	for _, path := range userKeyPaths {
+		raw, ok := lookupKeyPath(session, strings.Split(path, "."))
+		if !ok {
+			continue
+		}
+		var id sessionUserID
+		if err := json.Unmarshal(raw, &id); err != nil {
+			return -1, err
+		}
+		return int(id), nil
+	}
+	return -1, apperror.Unauthorized("Session does not contain a user ID")
+}
+
+// lookupKeyPath follows a sequence of object keys through decoded JSON
+func lookupKeyPath(obj map[string]json.RawMessage, keys []string) (json.RawMessage, bool) {
+	raw, ok := obj[keys[0]]
+	if !ok || string(raw) == "null" {
+		return nil, false
+	}
+	if len(keys) == 1 {
+		return raw, true
+	}
+	var child map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &child); err != nil {
+		return nil, false
+	}
+	return lookupKeyPath(child, keys[1:])
+}
@@ -0,0 +1,86 @@
+package user
+
+import "testing"
+
+func TestParseUserID(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		paths []string
+		id    int
+		ok    bool
+	}{
+		{
+			name:  "Passport session",
+			data:  `{"cookie":{"originalMaxAge":2592000000,"expires":"2018-05-01T00:00:00.000Z","httpOnly":true,"path":"/"},"passport":{"user":42}}`,
+			paths: DefaultUserKeyPaths,
+			id:    42,
+			ok:    true,
+		},
+		{
+			name:  "Passport session with string ID",
+			data:  `{"cookie":{"path":"/"},"passport":{"user":"17"}}`,
+			paths: DefaultUserKeyPaths,
+			id:    17,
+			ok:    true,
+		},
+		{
+			name:  "Top level user",
+			data:  `{"cookie":{"path":"/","httpOnly":true},"user":7,"flash":{}}`,
+			paths: DefaultUserKeyPaths,
+			id:    7,
+			ok:    true,
+		},
+		{
+			name:  "Custom key path",
+			data:  `{"cookie":{},"auth":{"account":{"id":99}}}`,
+			paths: []string{"auth.account.id"},
+			id:    99,
+			ok:    true,
+		},
+		{
+			name:  "Logged out passport session",
+			data:  `{"cookie":{"path":"/"},"passport":{}}`,
+			paths: DefaultUserKeyPaths,
+		},
+		{
+			name:  "Null user",
+			data:  `{"cookie":{"path":"/"},"user":null}`,
+			paths: DefaultUserKeyPaths,
+		},
+		{
+			name:  "Object instead of ID",
+			data:  `{"passport":{"user":{"id":3}}}`,
+			paths: DefaultUserKeyPaths,
+		},
+		{
+			name:  "Negative ID",
+			data:  `{"user":-1}`,
+			paths: DefaultUserKeyPaths,
+		},
+		{
+			name:  "Empty session",
+			data:  `{}`,
+			paths: DefaultUserKeyPaths,
+		},
+		{
+			name:  "Not JSON",
+			data:  `}}}"user":`,
+			paths: DefaultUserKeyPaths,
+		},
+		{
+			name:  "Empty string",
+			data:  ``,
+			paths: DefaultUserKeyPaths,
+		},
+	}
+	for _, test := range tests {
+		id, err := parseUserID(test.data, test.paths)
+		if test.ok && (err != nil || id != test.id) {
+			t.Errorf("Failed: %s - Expected user ID %d, got %d (%v)", test.name, test.id, id, err)
+		}
+		if !test.ok && err == nil {
+			t.Errorf("Failed: %s - Expected an error, got user ID %d", test.name, id)
+		}
+	}
+}
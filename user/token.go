@@ -0,0 +1,76 @@
+package user
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"../apperror"
+)
+
+// TokenAuthenticator identifies users by a self-contained token signed with a shared secret
+type TokenAuthenticator struct {
+	secret string
+}
+
+type tokenClaims struct {
+	User    User  `json:"user"`
+	Expires int64 `json:"exp"`
+}
+
+// CreateTokenAuthenticator generates an authenticator for tokens signed with the given secret
+func CreateTokenAuthenticator(secret string) (*TokenAuthenticator, error) {
+	if secret == "" {
+		return nil, errors.New("Token authentication requires a token secret")
+	}
+	return &TokenAuthenticator{secret: secret}, nil
+}
+
+// CreateToken issues a token for a user that expires after the given duration
+func (a *TokenAuthenticator) CreateToken(u User, expiresIn time.Duration) (string, error) {
+	b, err := json.Marshal(tokenClaims{User: u, Expires: time.Now().Add(expiresIn).Unix()})
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(b)
+	return payload + "." + a.sign(payload), nil
+}
+
+// Authenticate reads the token from a bearer Authorization header or, for socket handshakes, the token query parameter
+func (a *TokenAuthenticator) Authenticate(r *http.Request) (User, error) {
+	token := r.URL.Query().Get("token")
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token = strings.TrimPrefix(h, "Bearer ")
+	}
+	if token == "" {
+		return User{}, apperror.Unauthorized("Missing token")
+	}
+
+	dot := strings.LastIndex(token, ".")
+	if dot < 0 {
+		return User{}, apperror.Unauthorized("Token is not valid")
+	}
+	payload, signature := token[:dot], token[dot+1:]
+	if !hmacEqual(signature, a.sign(payload)) {
+		return User{}, apperror.Unauthorized("Token is not valid")
+	}
+	b, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return User{}, apperror.Unauthorized("Token is not valid")
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(b, &claims); err != nil {
+		return User{}, apperror.Unauthorized("Token is not valid")
+	}
+	if time.Now().Unix() > claims.Expires {
+		return User{}, apperror.Unauthorized("Token has expired")
+	}
+	return claims.User, nil
+}
+
+func (a *TokenAuthenticator) sign(payload string) string {
+	return base64.RawURLEncoding.EncodeToString(hmacSHA256(payload, a.secret))
+}
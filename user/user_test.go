@@ -0,0 +1,139 @@
+package user
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"../apperror"
+	"../dbtest"
+)
+
+func sessionRequest(cookie string) *http.Request {
+	r := httptest.NewRequest("GET", "/game/state", nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookieName, Value: cookie})
+	return r
+}
+
+func TestHostileCookieIsBound(t *testing.T) {
+	sids := []string{
+		`' OR '1'='1`,
+		`'; DROP TABLE "Sessions"; --`,
+		`abc\'); DELETE FROM users; --`,
+	}
+	for _, sid := range sids {
+		db, rec := dbtest.Open(nil)
+		auth, _ := CreateSessionAuthenticator(db, "secret")
+		cookie := url.PathEscape("s:" + sid + "." + sign(sid, "secret"))
+		if _, err := auth.Authenticate(sessionRequest(cookie)); err == nil {
+			t.Errorf("Failed: Expected session %q to be rejected", sid)
+		}
+		queries := rec.Queries()
+		if len(queries) != 1 {
+			t.Fatalf("Failed: Expected exactly 1 query, got %d", len(queries))
+		}
+		if queries[0].SQL != `SELECT data FROM "Sessions" WHERE sid = $1` {
+			t.Errorf("Failed: Expected session ID to be bound as an argument, query was...\n%s", queries[0].SQL)
+		}
+		if queries[0].Args[0] != sid {
+			t.Errorf("Failed: Expected argument %q, got %v", sid, queries[0].Args)
+		}
+		db.Close()
+	}
+}
+
+func TestForgedCookieIsRejected(t *testing.T) {
+	cookies := []string{
+		`' OR '1'='1`,
+		url.QueryEscape(`s:' OR '1'='1.` + sign(`' OR '1'='1`, "wrong secret")),
+		url.QueryEscape(`s:abcdefghijklmnopqrstuvwxyz012345`),
+	}
+	for _, c := range cookies {
+		db, rec := dbtest.Open(nil)
+		auth, _ := CreateSessionAuthenticator(db, "secret")
+		if _, err := auth.Authenticate(sessionRequest(c)); err == nil {
+			t.Errorf("Failed: Expected cookie %q to be rejected", c)
+		}
+		if len(rec.Queries()) != 0 {
+			t.Errorf("Failed: Expected cookie %q to be rejected before querying the database", c)
+		}
+		db.Close()
+	}
+}
+
+func TestGetByIDUsesPlaceholder(t *testing.T) {
+	db, rec := dbtest.Open(func(q dbtest.Query) ([]string, [][]driver.Value) {
+		return []string{"name", "email"}, [][]driver.Value{{"Alice", "alice@example.com"}}
+	})
+	defer db.Close()
+
+	u, err := GetByID(7, db)
+	if err != nil || u.Name != "Alice" {
+		t.Fatalf("Failed: Expected to fetch user 7, got %v (%v)", u, err)
+	}
+	q := rec.Queries()[0]
+	if q.SQL != `SELECT name, email FROM users WHERE id = $1` || q.Args[0] != int64(7) {
+		t.Errorf("Failed: Expected user ID to be bound as an argument, got %s %v", q.SQL, q.Args)
+	}
+}
+
+func TestGetByIDMissingUser(t *testing.T) {
+	db, _ := dbtest.Open(nil)
+	defer db.Close()
+
+	if _, err := GetByID(7, db); !errors.Is(err, apperror.ErrNotFound) {
+		t.Errorf("Failed: Expected a not found error for a missing user, got %v", err)
+	}
+}
+
+func TestSessionForMissingUserIsUnauthorized(t *testing.T) {
+	db, _ := dbtest.Open(func(q dbtest.Query) ([]string, [][]driver.Value) {
+		if q.SQL == `SELECT data FROM "Sessions" WHERE sid = $1` {
+			return []string{"data"}, [][]driver.Value{{`{"passport":{"user":7}}`}}
+		}
+		return []string{"name", "email"}, nil
+	})
+	defer db.Close()
+
+	auth, _ := CreateSessionAuthenticator(db, "secret")
+	cookie := url.QueryEscape("s:abc." + sign("abc", "secret"))
+	if _, err := auth.Authenticate(sessionRequest(cookie)); !errors.Is(err, apperror.ErrUnauthorized) {
+		t.Errorf("Failed: Expected a session for a deleted user to be unauthorized, got %v", err)
+	}
+}
+
+func TestSessionDatabaseErrorIsNotUnauthorized(t *testing.T) {
+	db, _ := dbtest.Open(nil)
+	db.Close()
+
+	auth, _ := CreateSessionAuthenticator(db, "secret")
+	cookie := url.QueryEscape("s:abc." + sign("abc", "secret"))
+	if _, err := auth.Authenticate(sessionRequest(cookie)); err == nil || errors.Is(err, apperror.ErrUnauthorized) {
+		t.Errorf("Failed: Expected a failed session query to be returned as is, got %v", err)
+	}
+}
+
+func TestCookieSignatureKeepsPlus(t *testing.T) {
+	sid := ""
+	for i := 0; !strings.Contains(sign(sid, "secret"), "+"); i++ {
+		sid = "session" + strconv.Itoa(i)
+	}
+	db, _ := dbtest.Open(func(q dbtest.Query) ([]string, [][]driver.Value) {
+		if q.SQL == `SELECT data FROM "Sessions" WHERE sid = $1` {
+			return []string{"data"}, [][]driver.Value{{`{"passport":{"user":7}}`}}
+		}
+		return []string{"name", "email"}, [][]driver.Value{{"Alice", "alice@example.com"}}
+	})
+	defer db.Close()
+
+	auth, _ := CreateSessionAuthenticator(db, "secret")
+	cookie := "s%3A" + sid + "." + sign(sid, "secret")
+	if u, err := auth.Authenticate(sessionRequest(cookie)); err != nil || u.ID != 7 {
+		t.Errorf("Failed: Expected a signature with an unencoded '+' to verify, got %v (%v)", u, err)
+	}
+}